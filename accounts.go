@@ -0,0 +1,123 @@
+package main
+
+import (
+	"billburner/cd"
+	"fmt"
+	"os"
+)
+
+// account is one login at a provider. Several accounts may use the same provider, e.g. our own AT&T account and the one for the rental property, as long as each has its own nickname. The login is read from .env, see .env.example.
+//
+// Example:
+//
+//	{"provider": "ameren", "nickname": "Rental", "credentials": "AMEREN_RENTAL", "household": "Rental"}
+type account struct {
+	Provider    string `json:"provider"`
+	Nickname    string `json:"nickname,omitempty"`
+	Credentials string `json:"credentials,omitempty"` // env prefix, AMEREN_RENTAL reads AMEREN_RENTAL_USERNAME and AMEREN_RENTAL_PASSWORD
+	Household   string `json:"household,omitempty"`
+	Statement   string `json:"statement,omitempty"` // CSS selector of the dashboard link that downloads the current statement
+	Human       *bool  `json:"human,omitempty"`     // type and click like a person; defaults to on for providers in humanInput
+}
+
+type credentials struct {
+	username string
+	password string
+}
+
+// provider is a site BillBurner knows how to log in to
+type provider struct {
+	name        string   // the company's name, as payee in budget exports
+	credentials string   // default env prefix when an account does not name one
+	bills       []string // bill types the site produces, in the order fetch fills them
+	fetch       func(creds credentials, bills []*Bill)
+}
+
+var providers = map[string]provider{
+	"att": {"AT&T", "ATT", []string{"Internet", "Wireless"}, func(creds credentials, bills []*Bill) {
+		getPhoneBill(bills[1], bills[0], creds)
+		if !bills[0].retrieved && bills[0].failure == "" {
+			bills[0].failure = bills[1].failure
+		}
+	}},
+	"stlo":      {"City of St. Louis Water", "STLO_EGOV", []string{"Water"}, func(creds credentials, bills []*Bill) { getWaterBill(bills[0], creds) }},
+	"ameren":    {"Ameren Missouri", "AMEREN", []string{"Power"}, func(creds credentials, bills []*Bill) { getPowerBill(bills[0], creds) }},
+	"spire":     {"Spire", "SPIRE", []string{"Gas"}, func(creds credentials, bills []*Bill) { getGasBill(bills[0], creds) }},
+	"stlmsd":    {"MSD", "STLMSD", []string{"Sewer"}, func(creds credentials, bills []*Bill) { getSewerBill(bills[0], creds) }},
+	"pennymac":  {"PennyMac", "PENNYMAC", []string{"Mortgage"}, func(creds credentials, bills []*Bill) { getMortgageBill(bills[0], creds) }},
+	"statefarm": {"State Farm", "STATE_FARM", []string{"Insurance"}, func(creds credentials, bills []*Bill) { getInsuranceBill(bills[0], creds) }},
+}
+
+// humanInput lists the providers whose sites watch for robotic input, so their flows run in cd's human input mode unless an account turns it off
+var humanInput = map[string]bool{"att": true}
+
+// defaultAccounts is used when the config file lists no accounts: one account per provider using the provider's own env prefix
+var defaultAccounts = []account{
+	{Provider: "att"},
+	{Provider: "stlo"},
+	{Provider: "ameren"},
+	{Provider: "spire"},
+	{Provider: "stlmsd"},
+	{Provider: "pennymac"},
+	{Provider: "statefarm"},
+}
+
+func (a *account) validate() error {
+	p, ok := providers[a.Provider]
+	if !ok {
+		return fmt.Errorf("unknown provider %q", a.Provider)
+	}
+	if a.Credentials == "" {
+		a.Credentials = p.credentials
+	}
+	return nil
+}
+
+func (a account) credentials() credentials {
+	return credentials{
+		username: os.Getenv(a.Credentials + "_USERNAME"),
+		password: os.Getenv(a.Credentials + "_PASSWORD"),
+	}
+}
+
+// billJob is one unit of work in a run: the bills it fills in and how to fill them
+type billJob struct {
+	provider  string
+	statement string
+	entries   []billEntry
+	fetch     func()
+}
+
+// accountJob creates the bills an account produces, tagged with the account and household
+func accountJob(a account) billJob {
+	p := providers[a.Provider]
+	job := billJob{provider: a.Provider, statement: a.Statement}
+
+	bills := make([]*Bill, len(p.bills))
+	for i, billType := range p.bills {
+		bills[i] = &Bill{}
+		job.entries = append(job.entries, billEntry{provider: a.Provider, name: billType, account: a.Nickname, household: a.Household, bill: bills[i]})
+	}
+
+	creds := a.credentials()
+	human := humanInput[a.Provider]
+	if a.Human != nil {
+		human = *a.Human
+	}
+	job.fetch = func() {
+		// Without a login the site would only show its sign-in error, so say which variables are missing instead
+		if creds.username == "" || creds.password == "" {
+			for _, bill := range bills {
+				bill.fail("%s_USERNAME and %s_PASSWORD must be set in .env for %s", a.Credentials, a.Credentials, a.Provider)
+			}
+			return
+		}
+		if human {
+			plain := browser
+			browser = cd.Human(browser, cd.HumanOptions{})
+			defer func() { browser = plain }()
+		}
+		p.fetch(creds, bills)
+	}
+	return job
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"billburner/cd"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// statementRecord indexes one archived statement file in the store
+type statementRecord struct {
+	Provider     string `json:"provider"`
+	Account      string `json:"account,omitempty"`
+	Period       string `json:"period"` // YYYY-MM of the due date the statement is for
+	DueDate      int64  `json:"dueDate,omitempty"`
+	Path         string `json:"path"`
+	SHA256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	DownloadedAt int64  `json:"downloadedAt"`
+
+	// Figures read from the statement itself
+	AmountDue   float64 `json:"amountDue,omitempty"`
+	PeriodStart int64   `json:"periodStart,omitempty"`
+	PeriodEnd   int64   `json:"periodEnd,omitempty"`
+	Usage       float64 `json:"usage,omitempty"`
+	UsageUnit   string  `json:"usageUnit,omitempty"`
+}
+
+func archiveDir() string {
+	if dir := os.Getenv("ARCHIVE_DIR"); dir != "" {
+		return dir
+	}
+	return "statements"
+}
+
+// downloadStatement downloads the account's current statement, reads it and files it in the archive. The provider's dashboard must still be showing. For single-bill accounts the statement is cross-checked against the scraped bill, or fills it in when the scrape failed.
+func downloadStatement(st *store, job billJob) {
+	if !cd.ElementExists(browser, job.statement, 5000) {
+		fmt.Println("No statement link found for", job.entries[0].label())
+		return
+	}
+
+	incoming := filepath.Join(archiveDir(), ".incoming")
+	path, err := cd.DownloadFile(browser, job.statement, incoming, 30000)
+	if err != nil {
+		fmt.Println("Error downloading statement:", err)
+		return
+	}
+
+	sd, err := readStatement(job.provider, path)
+	if err != nil {
+		fmt.Println("Error reading statement:", err)
+	}
+	if len(job.entries) == 1 {
+		reconcileStatement(job.entries[0], sd)
+	}
+
+	var dueDate int64
+	for _, entry := range job.entries {
+		if entry.bill.retrieved && entry.bill.dueDate != 0 {
+			dueDate = entry.bill.dueDate
+			break
+		}
+	}
+	if dueDate == 0 {
+		dueDate = sd.dueDate
+	}
+	if dueDate == 0 {
+		fmt.Println("Statement has no due date, not archiving", path)
+		return
+	}
+
+	rec, dup, err := archiveStatement(st, job.provider, job.entries[0].account, dueDate, path, sd)
+	if err != nil {
+		fmt.Println("Error archiving statement:", err)
+		return
+	}
+	if !dup {
+		fmt.Println("Archived statement:", rec.Path)
+	}
+}
+
+// archiveStatement moves a downloaded file to statements/<provider>/<account>/<period>.pdf and indexes it in the store. Files whose content is already archived are discarded and the existing record is returned with dup set.
+func archiveStatement(st *store, provider, account string, dueDate int64, path string, sd statementData) (rec statementRecord, dup bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rec, false, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	for _, existing := range st.Statements {
+		if existing.SHA256 == hash {
+			os.Remove(path)
+			return existing, true, nil
+		}
+	}
+
+	accountDir := account
+	if accountDir == "" {
+		accountDir = "default"
+	}
+	dir := filepath.Join(archiveDir(), safePathPart(provider), safePathPart(accountDir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return rec, false, fmt.Errorf("error creating archive directory: %v", err)
+	}
+
+	period := time.Unix(dueDate, 0).UTC().Format("2006-01")
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		ext = ".pdf"
+	}
+	target := filepath.Join(dir, period+ext)
+	if _, err := os.Stat(target); err == nil {
+		// A corrected statement for the same period is kept next to the original
+		target = filepath.Join(dir, period+"-"+hash[:8]+ext)
+	}
+	if err := os.Rename(path, target); err != nil {
+		return rec, false, fmt.Errorf("error moving statement into archive: %v", err)
+	}
+
+	rec = statementRecord{
+		Provider:     provider,
+		Account:      account,
+		Period:       period,
+		DueDate:      dueDate,
+		Path:         target,
+		SHA256:       hash,
+		Size:         int64(len(data)),
+		DownloadedAt: time.Now().Unix(),
+		AmountDue:    sd.amountDue,
+		PeriodStart:  sd.periodStart,
+		PeriodEnd:    sd.periodEnd,
+		Usage:        sd.usage,
+		UsageUnit:    sd.usageUnit,
+	}
+	st.Statements = append(st.Statements, rec)
+	return rec, false, nil
+}
+
+func renderStatements(st *store) {
+	rows := [][]string{{"Provider", "Account", "Period", "Size (KB)", "Downloaded", "Path"}}
+	for _, rec := range st.Statements {
+		rows = append(rows, []string{rec.Provider, rec.Account, rec.Period, fmt.Sprintf("%.1f", float64(rec.Size)/1024), time.Unix(rec.DownloadedAt, 0).Format("01/02/2006"), rec.Path})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+}
+
+// safePathPart keeps provider and account names from escaping the archive directory
+func safePathPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchiveStatement(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ARCHIVE_DIR", dir)
+	st := &store{}
+
+	// Each download is archived in turn into the same store
+	downloads := []struct {
+		name       string
+		account    string
+		due        string
+		content    string
+		want       string // path under the archive directory, with %s standing for the start of the content hash
+		wantDup    bool
+		wantStored int
+	}{
+		{"first period", "", "2026-04-17", "april statement", "ameren/default/2026-04.pdf", false, 1},
+		{"second period", "", "2026-05-17", "may statement", "ameren/default/2026-05.pdf", false, 2},
+		{"same statement downloaded again", "", "2026-05-17", "may statement", "ameren/default/2026-05.pdf", true, 2},
+		{"corrected statement kept next to the original", "", "2026-05-17", "may statement, corrected", "ameren/default/2026-05-%s.pdf", false, 3},
+		{"other account", "Rental House", "2026-05-17", "rental may statement", "ameren/Rental_House/2026-05.pdf", false, 4},
+	}
+	for _, d := range downloads {
+		t.Run(d.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "statement.pdf")
+			if err := os.WriteFile(path, []byte(d.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			rec, dup, err := archiveStatement(st, "ameren", d.account, date(d.due).Unix(), path, statementData{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dup != d.wantDup {
+				t.Errorf("dup = %v, want %v", dup, d.wantDup)
+			}
+			rel, _ := filepath.Rel(dir, rec.Path)
+			if want := strings.ReplaceAll(d.want, "%s", rec.SHA256[:8]); filepath.ToSlash(rel) != want {
+				t.Errorf("archived to %s, want %s", rel, want)
+			}
+			if data, err := os.ReadFile(rec.Path); err != nil || string(data) != d.content {
+				t.Errorf("archived file = %q, %v, want %q", data, err, d.content)
+			}
+			if _, err := os.Stat(path); err == nil {
+				t.Error("downloaded file left in place")
+			}
+			if rec.Period != d.due[:7] {
+				t.Errorf("period = %s, want %s", rec.Period, d.due[:7])
+			}
+			if len(st.Statements) != d.wantStored {
+				t.Errorf("store indexes %d statements, want %d", len(st.Statements), d.wantStored)
+			}
+		})
+	}
+}
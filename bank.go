@@ -0,0 +1,538 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// bankConfig describes how to read bank and credit card exports and which transactions pay which bills.
+//
+// Example:
+//
+//	{
+//	  "csv": {"date": "Posting Date", "payee": "Description", "amount": "Amount", "reference": "Reference", "dateFormat": "01/02/2006"},
+//	  "payees": [
+//	    {"bill": "Power", "pattern": "AMEREN"},
+//	    {"bill": "Power", "account": "Rental", "pattern": "AMEREN.*7781"},
+//	    {"bill": "Water", "pattern": "ST\\s*LOUIS.*WATER", "tolerance": 5}
+//	  ]
+//	}
+type bankConfig struct {
+	CSV    *csvLayout  `json:"csv,omitempty"`
+	Payees []payeeRule `json:"payees"`
+}
+
+// csvLayout names the columns of a bank's CSV export. Banks that split money in and out use debit and credit instead of amount.
+type csvLayout struct {
+	Date            string `json:"date"`
+	Payee           string `json:"payee"`
+	Amount          string `json:"amount,omitempty"`
+	Debit           string `json:"debit,omitempty"`
+	Credit          string `json:"credit,omitempty"`
+	Reference       string `json:"reference,omitempty"`
+	DateFormat      string `json:"dateFormat,omitempty"`      // Go layout, 01/02/2006 by default
+	OutflowPositive bool   `json:"outflowPositive,omitempty"` // credit card exports often show charges as positive amounts
+}
+
+// payeeRule matches the transactions that pay one bill series
+type payeeRule struct {
+	Bill      string  `json:"bill"`
+	Account   string  `json:"account,omitempty"`
+	Pattern   string  `json:"pattern"`             // regular expression matched against the payee and memo, case-insensitive
+	Tolerance float64 `json:"tolerance,omitempty"` // dollars the payment may differ from the bill, 1.00 by default
+	Days      int     `json:"days,omitempty"`      // days after the due date a payment still counts, 10 by default
+
+	re *regexp.Regexp
+}
+
+// bankTransaction is one imported transaction. Outflows are negative.
+type bankTransaction struct {
+	ID     string  `json:"id"`
+	Date   int64   `json:"date"`
+	Amount float64 `json:"amount"`
+	Payee  string  `json:"payee"`
+	Memo   string  `json:"memo,omitempty"`
+	Source string  `json:"source"`
+}
+
+// bankMatch ties a billing cycle to the transaction that paid it
+type bankMatch struct {
+	Type        string  `json:"type"`
+	Account     string  `json:"account,omitempty"`
+	DueDate     int64   `json:"dueDate"`
+	Transaction string  `json:"transaction"`
+	Reference   string  `json:"reference"` // shown to the user: the bank's reference when it has one, otherwise payee and date
+	Amount      float64 `json:"amount"`
+	Date        int64   `json:"date"`
+}
+
+func (m bankMatch) key() billKey {
+	return billKey{m.Type, m.Account}
+}
+
+// How long before the due date a payment can be made for a cycle
+const paymentLeadDays = 35
+
+func (bc *bankConfig) validate() error {
+	if bc.CSV != nil {
+		if bc.CSV.Date == "" || bc.CSV.Payee == "" {
+			return fmt.Errorf("csv layout needs date and payee columns")
+		}
+		if bc.CSV.Amount == "" && bc.CSV.Debit == "" {
+			return fmt.Errorf("csv layout needs an amount or debit column")
+		}
+		if bc.CSV.DateFormat == "" {
+			bc.CSV.DateFormat = "01/02/2006"
+		}
+	}
+
+	for i := range bc.Payees {
+		rule := &bc.Payees[i]
+		re, err := regexp.Compile(`(?i)` + rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern for %s: %v", rule.Bill, err)
+		}
+		rule.re = re
+		if rule.Tolerance == 0 {
+			rule.Tolerance = 1
+		}
+		if rule.Days == 0 {
+			rule.Days = 10
+		}
+	}
+	return nil
+}
+
+// importBankFile reads transactions from an OFX, QFX or CSV export
+func importBankFile(bc *bankConfig, path string) ([]bankTransaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	source := filepath.Base(path)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ofx", ".qfx":
+		return parseOFX(string(data), source)
+	case ".csv":
+		if bc.CSV == nil {
+			return nil, fmt.Errorf("no csv layout configured")
+		}
+		return parseBankCSV(bc.CSV, string(data), source)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q", filepath.Ext(path))
+	}
+}
+
+var (
+	ofxTransactionRe    = regexp.MustCompile(`(?i)<STMTTRN>`)
+	ofxTransactionEndRe = regexp.MustCompile(`(?i)</STMTTRN>|</BANKTRANLIST>`)
+	ofxFieldRe          = regexp.MustCompile(`(?i)<([A-Z0-9.]+)>([^<\r\n]*)`)
+)
+
+// parseOFX reads the statement transactions of an OFX 1.x (SGML) or 2.x (XML) file. QFX is OFX with extra Quicken tags.
+func parseOFX(data, source string) ([]bankTransaction, error) {
+	var txns []bankTransaction
+	starts := ofxTransactionRe.FindAllStringIndex(data, -1)
+	for i, start := range starts {
+		// A transaction runs to its closing tag, which SGML files may leave out, or else to the next transaction
+		body := data[start[1]:]
+		if i+1 < len(starts) {
+			body = data[start[1]:starts[i+1][0]]
+		}
+		if end := ofxTransactionEndRe.FindStringIndex(body); end != nil {
+			body = body[:end[0]]
+		}
+
+		fields := map[string]string{}
+		for _, f := range ofxFieldRe.FindAllStringSubmatch(body, -1) {
+			fields[strings.ToUpper(f[1])] = strings.TrimSpace(f[2])
+		}
+
+		amount, err := strconv.ParseFloat(fields["TRNAMT"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount %q in %s", fields["TRNAMT"], source)
+		}
+		posted := fields["DTPOSTED"]
+		if len(posted) < 8 {
+			return nil, fmt.Errorf("invalid date %q in %s", posted, source)
+		}
+		date, err := time.ParseInLocation("20060102", posted[:8], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q in %s", posted, source)
+		}
+
+		txn := bankTransaction{
+			ID:     fields["FITID"],
+			Date:   date.Unix(),
+			Amount: amount,
+			Payee:  fields["NAME"],
+			Memo:   fields["MEMO"],
+			Source: source,
+		}
+		if txn.Payee == "" {
+			txn.Payee = fields["PAYEE"]
+		}
+		txns = append(txns, txn)
+	}
+	if len(txns) == 0 {
+		return nil, fmt.Errorf("no transactions found in %s", source)
+	}
+	hashIDs(txns)
+	return txns, nil
+}
+
+// parseBankCSV reads a CSV export using the configured column names
+func parseBankCSV(layout *csvLayout, data, source string) ([]bankTransaction, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", source, err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("no transactions found in %s", source)
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	get := func(row []string, name string) string {
+		i, ok := columns[name]
+		if name == "" || !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	for _, name := range []string{layout.Date, layout.Payee, layout.Amount, layout.Debit, layout.Credit, layout.Reference} {
+		if _, ok := columns[name]; name != "" && !ok {
+			return nil, fmt.Errorf("column %q not found in %s", name, source)
+		}
+	}
+
+	var txns []bankTransaction
+	for n, row := range rows[1:] {
+		date, err := time.ParseInLocation(layout.DateFormat, get(row, layout.Date), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid date %q", source, n+2, get(row, layout.Date))
+		}
+
+		var amount float64
+		if layout.Amount != "" {
+			amount, err = parseMoney(get(row, layout.Amount))
+			if layout.OutflowPositive {
+				amount = -amount
+			}
+		} else {
+			var debit, credit float64
+			if debit, err = parseMoney(get(row, layout.Debit)); err == nil {
+				credit, err = parseMoney(get(row, layout.Credit))
+			}
+			amount = credit - math.Abs(debit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", source, n+2, err)
+		}
+
+		txn := bankTransaction{
+			ID:     get(row, layout.Reference),
+			Date:   date.Unix(),
+			Amount: amount,
+			Payee:  get(row, layout.Payee),
+			Source: source,
+		}
+		txns = append(txns, txn)
+	}
+	hashIDs(txns)
+	return txns, nil
+}
+
+// parseMoney reads amounts like "-1,234.56", "$45.00" or "(45.00)". Empty cells are zero.
+func parseMoney(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	s = strings.NewReplacer("(", "", ")", "", "$", "", ",", "", " ", "").Replace(s)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// transactionHash identifies transactions from exports that carry no reference, so importing the same file twice adds nothing
+func transactionHash(txn bankTransaction) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%.2f|%s|%s", txn.Date, txn.Amount, txn.Payee, txn.Memo)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// hashIDs identifies the transactions of one export that have no FITID or reference column by their contents. Identical ones, such as two equal charges at the same shop on one day, get the number of their occurrence appended from the second on, so they are not merged.
+func hashIDs(txns []bankTransaction) {
+	seen := map[string]int{}
+	for i := range txns {
+		if txns[i].ID != "" {
+			continue
+		}
+		hash := transactionHash(txns[i])
+		seen[hash]++
+		txns[i].ID = hash
+		if n := seen[hash]; n > 1 {
+			txns[i].ID = fmt.Sprintf("%s-%d", hash, n)
+		}
+	}
+}
+
+// addTransactions stores transactions that are not already known and returns how many were new
+func (st *store) addTransactions(txns []bankTransaction) int {
+	known := map[string]bool{}
+	for _, txn := range st.Transactions {
+		known[txn.ID] = true
+	}
+	added := 0
+	for _, txn := range txns {
+		if known[txn.ID] {
+			continue
+		}
+		known[txn.ID] = true
+		st.Transactions = append(st.Transactions, txn)
+		added++
+	}
+	return added
+}
+
+// match returns the transaction that paid a billing cycle, if one was found
+func (st *store) match(key billKey, dueDate int64) (bankMatch, bool) {
+	for _, m := range st.Matches {
+		if m.key() == key && m.DueDate == dueDate {
+			return m, true
+		}
+	}
+	return bankMatch{}, false
+}
+
+// rule finds the payee rule for a bill series. A rule for a specific account wins over one for the whole bill type.
+func (bc *bankConfig) rule(key billKey) (payeeRule, bool) {
+	var match payeeRule
+	found := false
+	for _, rule := range bc.Payees {
+		if rule.Bill != key.Type {
+			continue
+		}
+		if rule.Account == key.Account {
+			return rule, true
+		}
+		if rule.Account == "" {
+			match, found = rule, true
+		}
+	}
+	return match, found
+}
+
+// reconcilePayments matches stored billing cycles to stored transactions. Each transaction pays at most one cycle; the closest amount wins, then the closest date. Existing matches are kept.
+func reconcilePayments(bc *bankConfig, st *store) []bankMatch {
+	used := map[string]bool{}
+	for _, m := range st.Matches {
+		used[m.Transaction] = true
+	}
+
+	var added []bankMatch
+	for _, key := range st.keys() {
+		rule, ok := bc.rule(key)
+		if !ok {
+			continue
+		}
+		for _, rec := range st.cycles(key) {
+			owed := rec.owed()
+			if owed <= 0 {
+				continue
+			}
+			if _, ok := st.match(key, rec.DueDate); ok {
+				continue
+			}
+
+			due := time.Unix(rec.DueDate, 0)
+			from := due.AddDate(0, 0, -paymentLeadDays).Unix()
+			to := due.AddDate(0, 0, rule.Days).Unix()
+
+			best := -1
+			for i, txn := range st.Transactions {
+				if used[txn.ID] || txn.Amount >= 0 || txn.Date < from || txn.Date > to {
+					continue
+				}
+				if !rule.re.MatchString(txn.Payee + " " + txn.Memo) {
+					continue
+				}
+				diff := math.Abs(-txn.Amount - owed)
+				if diff > rule.Tolerance+0.005 {
+					continue
+				}
+				if best < 0 || closer(txn, st.Transactions[best], owed, rec.DueDate) {
+					best = i
+				}
+			}
+			if best < 0 {
+				continue
+			}
+
+			txn := st.Transactions[best]
+			used[txn.ID] = true
+			m := bankMatch{
+				Type:        key.Type,
+				Account:     key.Account,
+				DueDate:     rec.DueDate,
+				Transaction: txn.ID,
+				Reference:   txn.reference(),
+				Amount:      -txn.Amount,
+				Date:        txn.Date,
+			}
+			st.Matches = append(st.Matches, m)
+			added = append(added, m)
+		}
+	}
+	return added
+}
+
+// closer reports whether a is a better match than b for a bill of the given amount and due date
+func closer(a, b bankTransaction, owed float64, due int64) bool {
+	da, db := math.Abs(-a.Amount-owed), math.Abs(-b.Amount-owed)
+	if math.Abs(da-db) >= 0.005 {
+		return da < db
+	}
+	return absInt64(a.Date-due) < absInt64(b.Date-due)
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// reference is how a transaction is shown once it has been matched: the bank's own id when it looks like one, otherwise the payee and date
+func (txn bankTransaction) reference() string {
+	if hash, _, _ := strings.Cut(txn.ID, "-"); hash != transactionHash(txn) {
+		return txn.ID
+	}
+	return fmt.Sprintf("%s %s", txn.Payee, time.Unix(txn.Date, 0).Format("01/02/2006"))
+}
+
+// owed is what a cycle billed, before any payment brought the amount due down
+func (rec billRecord) owed() float64 {
+	if rec.StatementBalance > 0 {
+		return rec.StatementBalance
+	}
+	return rec.AmountDue
+}
+
+// unreconciledCycles lists cycles with a payee rule that are past due and have no matching transaction. Only cycles whose whole payment window falls within the imported transactions are listed, since the others can't be checked yet.
+func unreconciledCycles(bc *bankConfig, st *store, now time.Time) []billRecord {
+	if len(st.Transactions) == 0 {
+		return nil
+	}
+	first, last := st.Transactions[0].Date, st.Transactions[0].Date
+	for _, txn := range st.Transactions {
+		first = min(first, txn.Date)
+		last = max(last, txn.Date)
+	}
+
+	var out []billRecord
+	for _, key := range st.keys() {
+		rule, ok := bc.rule(key)
+		if !ok {
+			continue
+		}
+		for _, rec := range st.cycles(key) {
+			if rec.owed() <= 0 || rec.DueDate >= now.Unix() || rec.DueDate < first {
+				continue
+			}
+			// A payment made late but within the rule's days may not have been imported yet
+			if time.Unix(rec.DueDate, 0).AddDate(0, 0, rule.Days).Unix() > last {
+				continue
+			}
+			if _, ok := st.match(key, rec.DueDate); !ok {
+				out = append(out, rec)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DueDate < out[j].DueDate })
+	return out
+}
+
+// bankCommand imports exports with "bank import <file>...", then shows matched payments and past-due bills without one
+func bankCommand(args []string) error {
+	if cfg.Bank == nil {
+		return fmt.Errorf("no bank configured")
+	}
+
+	st, err := loadStore(storePath())
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		if args[0] != "import" || len(args) < 2 {
+			return fmt.Errorf("usage: bank [import <file>...]")
+		}
+		for _, path := range args[1:] {
+			txns, err := importBankFile(cfg.Bank, path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Imported %d new transactions from %s\n", st.addTransactions(txns), path)
+		}
+		for _, m := range reconcilePayments(cfg.Bank, st) {
+			fmt.Printf("Matched %s due %s to %s\n", m.key().label(), time.Unix(m.DueDate, 0).Format("01/02/2006"), m.Reference)
+		}
+		if err := st.save(); err != nil {
+			return err
+		}
+	}
+
+	renderReconciliation(cfg.Bank, st)
+	return nil
+}
+
+func renderReconciliation(bc *bankConfig, st *store) {
+	rows := [][]string{{"Bill", "Due Date", "Paid ($)", "Paid On", "Reference"}}
+	for _, m := range st.Matches {
+		rows = append(rows, []string{m.key().label(), time.Unix(m.DueDate, 0).Format("01/02/2006"), fmt.Sprintf("%.2f", m.Amount), time.Unix(m.Date, 0).Format("01/02/2006"), m.Reference})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+
+	now := time.Now()
+	unmatched := unreconciledCycles(bc, st, now)
+	if len(unmatched) == 0 {
+		fmt.Println("Every past-due bill has a matching payment.")
+		return
+	}
+	rows = [][]string{{"Bill", "Due Date", "Billed ($)", "Days Overdue"}}
+	for _, rec := range unmatched {
+		rows = append(rows, []string{rec.key().label(), time.Unix(rec.DueDate, 0).Format("01/02/2006"), fmt.Sprintf("%.2f", rec.owed()), strconv.Itoa(daysOverdue(rec.DueDate, now))})
+	}
+	pterm.Warning.Println("Past-due bills without a matching payment:")
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+}
+
+// daysOverdue counts whole days since a due date. Unlike daysUntil it does not cap bills that are long overdue.
+func daysOverdue(dueDate int64, now time.Time) int {
+	return int(now.Sub(time.Unix(dueDate, 0)).Hours() / 24)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOFX(t *testing.T) {
+	// OFX 1.x leaves elements unclosed, 2.x is XML
+	sgml := `OFXHEADER:100
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20260412120000[-5:EST]<TRNAMT>-120.50<FITID>A1<NAME>AMEREN MISSOURI<MEMO>WEB PMT
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20260415<TRNAMT>2000.00<FITID>A2<NAME>PAYROLL
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+	xml := `<?xml version="1.0"?><OFX><BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20260501</DTPOSTED><TRNAMT>-40.00</TRNAMT><PAYEE>SPIRE</PAYEE></STMTTRN>
+</BANKTRANLIST></OFX>`
+
+	txns, err := parseOFX(sgml, "bank.ofx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(txns))
+	}
+	if got := txns[0]; got.ID != "A1" || got.Amount != -120.50 || got.Payee != "AMEREN MISSOURI" || got.Memo != "WEB PMT" || got.Date != date("2026-04-12").Unix() || got.Source != "bank.ofx" {
+		t.Errorf("first transaction = %+v", got)
+	}
+	if txns[1].Amount != 2000 {
+		t.Errorf("second amount = %v, want 2000", txns[1].Amount)
+	}
+
+	txns, err = parseOFX(xml, "card.qfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without a FITID the transaction is identified by its contents, and PAYEE stands in for NAME
+	if len(txns) != 1 || txns[0].Payee != "SPIRE" || txns[0].ID != transactionHash(txns[0]) {
+		t.Errorf("transactions = %+v", txns)
+	}
+
+	// Identical charges without a FITID are kept apart, and keep their ids when the file is read again
+	twice := `<OFX><BANKTRANLIST>
+<STMTTRN><DTPOSTED>20260501<TRNAMT>-4.50<NAME>COFFEE SHOP</STMTTRN>
+<STMTTRN><DTPOSTED>20260501<TRNAMT>-4.50<NAME>COFFEE SHOP</STMTTRN>
+</BANKTRANLIST></OFX>`
+	txns, err = parseOFX(twice, "card.qfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := parseOFX(twice, "card.qfx")
+	if len(txns) != 2 || txns[0].ID == txns[1].ID || txns[0].ID != again[0].ID || txns[1].ID != again[1].ID {
+		t.Errorf("identical transactions = %+v, read again %+v", txns, again)
+	}
+	st := &store{}
+	if n := st.addTransactions(append(txns, again...)); n != 2 {
+		t.Errorf("stored %d of the identical transactions, want 2", n)
+	}
+	if ref := txns[1].reference(); ref != "COFFEE SHOP 05/01/2026" {
+		t.Errorf("reference() = %q, want payee and date", ref)
+	}
+
+	if _, err := parseOFX("<OFX></OFX>", "empty.ofx"); err == nil {
+		t.Error("no error for a file without transactions")
+	}
+	if _, err := parseOFX("<STMTTRN><DTPOSTED>20260412<TRNAMT>abc</STMTTRN>", "bad.ofx"); err == nil {
+		t.Error("no error for an invalid amount")
+	}
+}
+
+func TestParseBankCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  csvLayout
+		data    string
+		amounts []float64
+		wantErr string
+	}{
+		{
+			name:    "amount column",
+			layout:  csvLayout{Date: "Posting Date", Payee: "Description", Amount: "Amount", Reference: "Reference"},
+			data:    "\ufeffPosting Date,Description,Amount,Reference\n04/12/2026,AMEREN,\"-1,120.50\",R1\n04/13/2026,REFUND,$45.00,R2\n04/14/2026,FEE,(3.00),R3\n",
+			amounts: []float64{-1120.50, 45, -3},
+		},
+		{
+			name:    "charges shown as positive",
+			layout:  csvLayout{Date: "Date", Payee: "Payee", Amount: "Amount", OutflowPositive: true},
+			data:    "Date,Payee,Amount\n04/12/2026,SPIRE,40.00\n",
+			amounts: []float64{-40},
+		},
+		{
+			name:    "debit and credit columns",
+			layout:  csvLayout{Date: "Date", Payee: "Payee", Debit: "Debit", Credit: "Credit"},
+			data:    "Date,Payee,Debit,Credit\n04/12/2026,WATER,62.10,\n04/13/2026,PAYROLL,,2000\n",
+			amounts: []float64{-62.10, 2000},
+		},
+		{
+			name:    "missing column",
+			layout:  csvLayout{Date: "Date", Payee: "Payee", Amount: "Amount"},
+			data:    "Date,Description,Amount\n04/12/2026,X,1\n",
+			wantErr: `column "Payee" not found`,
+		},
+		{
+			name:    "invalid date",
+			layout:  csvLayout{Date: "Date", Payee: "Payee", Amount: "Amount"},
+			data:    "Date,Payee,Amount\n04/12/2026,X,1\n2026-04-13,Y,2\n",
+			wantErr: "line 3: invalid date",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := &bankConfig{CSV: &tt.layout}
+			if err := bc.validate(); err != nil {
+				t.Fatal(err)
+			}
+			txns, err := parseBankCSV(bc.CSV, tt.data, "bank.csv")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txns) != len(tt.amounts) {
+				t.Fatalf("got %d transactions, want %d", len(txns), len(tt.amounts))
+			}
+			for i, txn := range txns {
+				if txn.Amount != tt.amounts[i] || txn.ID == "" {
+					t.Errorf("transaction %d = %+v, want amount %v", i, txn, tt.amounts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReconcilePayments(t *testing.T) {
+	bc := &bankConfig{Payees: []payeeRule{
+		{Bill: "Power", Pattern: "AMEREN"},
+		{Bill: "Water", Pattern: "WATER", Tolerance: 5},
+	}}
+	if err := bc.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	st := &store{
+		Records: []billRecord{
+			{Type: "Power", AmountDue: 120.50, DueDate: date("2026-04-20").Unix()},
+			{Type: "Power", AmountDue: 98.10, DueDate: date("2026-05-20").Unix()},
+			{Type: "Water", AmountDue: 60.00, DueDate: date("2026-04-25").Unix()},
+			{Type: "Gas", AmountDue: 40.00, DueDate: date("2026-04-22").Unix()}, // no payee rule
+		},
+		Transactions: []bankTransaction{
+			{ID: "far", Date: date("2026-04-19").Unix(), Amount: -121.40, Payee: "AMEREN MO"},
+			{ID: "close", Date: date("2026-04-10").Unix(), Amount: -120.50, Payee: "AMEREN MO"},
+			{ID: "early", Date: date("2026-02-01").Unix(), Amount: -98.10, Payee: "AMEREN MO"},  // before the payment window of May
+			{ID: "late", Date: date("2026-06-15").Unix(), Amount: -98.10, Payee: "AMEREN MO"},   // after the payment window of May
+			{ID: "water", Date: date("2026-05-02").Unix(), Amount: -64.00, Payee: "CITY WATER"}, // late but within 10 days, off by less than the tolerance
+			{ID: "gas", Date: date("2026-04-20").Unix(), Amount: -40.00, Payee: "SPIRE"},
+		},
+	}
+
+	added := reconcilePayments(bc, st)
+	got := map[string]string{}
+	for _, m := range added {
+		got[m.Type+" "+m.Transaction] = m.Reference
+	}
+	if len(added) != 2 || got["Power close"] != "close" || got["Water water"] != "water" {
+		t.Fatalf("matches = %+v, want Power paid by close and Water by water", added)
+	}
+
+	// Running again finds nothing new and keeps what was matched
+	if again := reconcilePayments(bc, st); len(again) != 0 || len(st.Matches) != 2 {
+		t.Errorf("second run added %+v, store has %d matches", again, len(st.Matches))
+	}
+
+	// Once the May payment is imported it is matched, and the leftover April candidate stays unused
+	st.Transactions = append(st.Transactions, bankTransaction{ID: "may", Date: date("2026-05-18").Unix(), Amount: -98.10, Payee: "AMEREN MO"})
+	if added := reconcilePayments(bc, st); len(added) != 1 || added[0].Transaction != "may" {
+		t.Errorf("matches after import = %+v, want only may", added)
+	}
+}
+
+func TestUnreconciledCycles(t *testing.T) {
+	bc := &bankConfig{Payees: []payeeRule{{Bill: "Power", Pattern: "AMEREN"}}}
+	if err := bc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	st := &store{
+		Records: []billRecord{
+			{Type: "Power", AmountDue: 90, DueDate: date("2026-02-20").Unix()}, // before the first import
+			{Type: "Power", AmountDue: 95, DueDate: date("2026-03-20").Unix()},
+			{Type: "Power", AmountDue: 99, DueDate: date("2026-04-20").Unix()}, // payment window not covered yet
+		},
+		Transactions: []bankTransaction{
+			{ID: "a", Date: date("2026-03-01").Unix(), Amount: -10, Payee: "OTHER"},
+			{ID: "b", Date: date("2026-04-25").Unix(), Amount: -10, Payee: "OTHER"},
+		},
+	}
+	got := unreconciledCycles(bc, st, date("2026-05-01"))
+	if len(got) != 1 || got[0].AmountDue != 95 {
+		t.Errorf("unreconciled = %+v, want only the March cycle", got)
+	}
+	if days := daysOverdue(date("2026-03-20").Unix(), date("2026-05-01")); days != 42 {
+		t.Errorf("daysOverdue = %d, want 42", days)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Budgeting apps the upcoming bills can be exported for
+const (
+	budgetYNAB    = "ynab"
+	budgetActual  = "actual"
+	budgetFirefly = "firefly"
+)
+
+// budgetConfig writes the upcoming bills as CSV files ready to import into budgeting apps.
+//
+// Example:
+//
+//	{
+//	  "formats": ["ynab", "firefly"],
+//	  "account": "Checking",
+//	  "bills": {"Power": {"payee": "Ameren Missouri", "category": "Utilities: Electric"}}
+//	}
+type budgetConfig struct {
+	Formats []string                 `json:"formats"`
+	Dir     string                   `json:"dir,omitempty"`     // reports/budget by default
+	Account string                   `json:"account,omitempty"` // asset account bills are paid from, used by Firefly III
+	Bills   map[string]budgetMapping `json:"bills,omitempty"`   // keyed by bill type
+}
+
+// budgetMapping names a bill type the way the budget does
+type budgetMapping struct {
+	Payee    string `json:"payee,omitempty"`    // the provider's name by default
+	Category string `json:"category,omitempty"` // the bill type by default
+}
+
+// budgetRow is one upcoming bill in the budget's terms
+type budgetRow struct {
+	id       string
+	date     time.Time
+	payee    string
+	category string
+	memo     string
+	amount   float64
+}
+
+func (bc *budgetConfig) validate() error {
+	if len(bc.Formats) == 0 {
+		return fmt.Errorf("no formats")
+	}
+	for _, f := range bc.Formats {
+		switch f {
+		case budgetYNAB, budgetActual, budgetFirefly:
+		default:
+			return fmt.Errorf("unknown format %q", f)
+		}
+	}
+	if bc.Dir == "" {
+		bc.Dir = filepath.Join(reportDir(), "budget")
+	}
+	return nil
+}
+
+// budgetRows lists the bills shown in the bill table that are not yet paid, soonest first. Bills settled by their last payment or a matched bank transaction are left out, as in the forecast, since the budgeting app already has that transaction.
+func budgetRows(bc *budgetConfig, bills []billEntry) []budgetRow {
+	var rows []budgetRow
+	for _, entry := range bills {
+		bill := entry.bill
+		if !bill.retrieved || bill.dueDate == 0 || bill.amountDue <= 0 || bill.status() == statusPaid {
+			continue
+		}
+
+		m := bc.Bills[entry.name]
+		if m.Payee == "" {
+			m.Payee = entry.name
+			if p, ok := providers[entry.provider]; ok {
+				m.Payee = p.name
+			}
+		}
+		if m.Category == "" {
+			m.Category = entry.name
+		}
+
+		memo := entry.label() + " bill"
+		if entry.household != "" {
+			memo += ", " + entry.household
+		}
+		rows = append(rows, budgetRow{
+			id:       journalID(entry.key(), bill.dueDate),
+			date:     time.Unix(bill.dueDate, 0),
+			payee:    m.Payee,
+			category: m.Category,
+			memo:     memo,
+			amount:   bill.amountDue,
+		})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].date.Before(rows[j].date) })
+	return rows
+}
+
+// budgetRecords lays rows out in the columns each app's importer expects
+func (bc *budgetConfig) budgetRecords(format string, rows []budgetRow) [][]string {
+	var records [][]string
+	switch format {
+	case budgetYNAB:
+		records = append(records, []string{"Date", "Payee", "Category", "Memo", "Outflow", "Inflow"})
+		for _, r := range rows {
+			records = append(records, []string{r.date.Format("01/02/2006"), r.payee, r.category, r.memo, fmt.Sprintf("%.2f", r.amount), ""})
+		}
+	case budgetActual:
+		records = append(records, []string{"Date", "Payee", "Category", "Notes", "Amount"})
+		for _, r := range rows {
+			records = append(records, []string{r.date.Format(dateLayout), r.payee, r.category, r.memo, fmt.Sprintf("%.2f", -r.amount)})
+		}
+	case budgetFirefly:
+		records = append(records, []string{"date", "description", "amount", "currency_code", "source_name", "destination_name", "category_name", "notes", "external_id"})
+		for _, r := range rows {
+			records = append(records, []string{r.date.Format(dateLayout), r.memo, fmt.Sprintf("%.2f", -r.amount), "USD", bc.Account, r.payee, r.category, "", r.id})
+		}
+	}
+	return records
+}
+
+// writeBudgetExports replaces each configured export with the current upcoming bills
+func writeBudgetExports(bc *budgetConfig, bills []billEntry) error {
+	if err := os.MkdirAll(bc.Dir, 0755); err != nil {
+		return fmt.Errorf("error creating budget export directory: %v", err)
+	}
+
+	rows := budgetRows(bc, bills)
+	for _, format := range bc.Formats {
+		path := filepath.Join(bc.Dir, format+".csv")
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %v", path, err)
+		}
+		w := csv.NewWriter(f)
+		w.WriteAll(bc.budgetRecords(format, rows))
+		f.Close()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("error writing %s: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteBudgetExports(t *testing.T) {
+	bc := &budgetConfig{
+		Formats: []string{budgetYNAB, budgetActual, budgetFirefly},
+		Dir:     t.TempDir(),
+		Account: "Checking",
+		Bills:   map[string]budgetMapping{"Water": {Payee: "St. Louis Water Division", Category: "Utilities: Water"}},
+	}
+	if err := bc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	bills := []billEntry{
+		{provider: "att", name: "Internet", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 80, dueDate: date("2026-05-20").Unix()}},
+		{provider: "ameren", name: "Power", account: "Rental", household: "Rental", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 120.5, dueDate: date("2026-05-17").Unix()}},
+		{provider: "stlo", name: "Water", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 45.1, dueDate: date("2026-05-25").Unix(), autopay: true}},
+		{name: "Rent", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 1500, dueDate: date("2026-06-01").Unix()}},
+		{provider: "spire", name: "Gas", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 0, dueDate: date("2026-05-08").Unix()}},                                // paid
+		{provider: "att", name: "Wireless", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 95, dueDate: date("2026-05-20").Unix(), paidReference: "ACH 1234"}}, // matched to the bank
+	}
+	if err := writeBudgetExports(bc, bills); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := map[string]string{
+		budgetYNAB: "Date,Payee,Category,Memo,Outflow,Inflow\n" +
+			"05/17/2026,Ameren Missouri,Power,\"Power (Rental) bill, Rental\",120.50,\n" +
+			"05/20/2026,AT&T,Internet,Internet bill,80.00,\n" +
+			"05/25/2026,St. Louis Water Division,Utilities: Water,Water bill,45.10,\n" +
+			"06/01/2026,Rent,Rent,Rent bill,1500.00,\n",
+		budgetActual: "Date,Payee,Category,Notes,Amount\n" +
+			"2026-05-17,Ameren Missouri,Power,\"Power (Rental) bill, Rental\",-120.50\n" +
+			"2026-05-20,AT&T,Internet,Internet bill,-80.00\n" +
+			"2026-05-25,St. Louis Water Division,Utilities: Water,Water bill,-45.10\n" +
+			"2026-06-01,Rent,Rent,Rent bill,-1500.00\n",
+		budgetFirefly: "date,description,amount,currency_code,source_name,destination_name,category_name,notes,external_id\n" +
+			"2026-05-17,\"Power (Rental) bill, Rental\",-120.50,USD,Checking,Ameren Missouri,Power,,Power/Rental/2026-05-17\n" +
+			"2026-05-20,Internet bill,-80.00,USD,Checking,AT&T,Internet,,Internet/2026-05-20\n" +
+			"2026-05-25,Water bill,-45.10,USD,Checking,St. Louis Water Division,Utilities: Water,,Water/2026-05-25\n" +
+			"2026-06-01,Rent bill,-1500.00,USD,Checking,Rent,Rent,,Rent/2026-06-01\n",
+	}
+	for _, format := range bc.Formats {
+		t.Run(format, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(bc.Dir, format+".csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != golden[format] {
+				t.Errorf("%s.csv =\n%s\nwant\n%s", format, got, golden[format])
+			}
+		})
+	}
+}
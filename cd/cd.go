@@ -7,43 +7,63 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/chromedp/cdproto/browser"
 	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/cdproto/dom"
-	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
 )
 
-var bypassScript = `(function(w, n, wn) {
-	Object.defineProperty(n, 'webdriver', {
-		get: () => false,
-	});
-
-	Object.defineProperty(n, 'plugins', {
-		get: () => [1, 2, 3, 4, 5],
-	});
-
-	Object.defineProperty(n, 'languages', {
-		get: () => ['en-US', 'en'],
-	});
-
-	w.chrome = {
-		runtime: {},
-	};
-
-	const originalQuery = wn.permissions.query;
-	return wn.permissions.query = (parameters) => (
-		parameters.name === 'notifications' ?
-		Promise.resolve({
-			state: Notification.permission
-		}) :
-		originalQuery(parameters)
-	);
-})(window, navigator, window.navigator);`
+// BrowserOptions configures a browser started by NewBrowser. The zero value starts a visible browser with a persistent profile, the way CreateBrowser(false, false, false) does.
+type BrowserOptions struct {
+	// Headless determines if the browser window is visible or not.
+	Headless bool
+
+	// Fresh determines if the browser should start with a clean profile.
+	Fresh bool
+
+	// Bypass determines if the browser should bypass potential bot detection.
+	Bypass bool
+
+	// Evasions picks the stealth patches applied when Bypass is set, all of them by default. See Evasion.
+	Evasions []Evasion
+
+	// UserAgent replaces the browser's user agent string when set.
+	UserAgent string
+
+	// Locale sets the browser language and Accept-Language header, e.g. "en-US".
+	Locale string
+
+	// Timezone overrides the timezone pages see, e.g. "America/Chicago".
+	Timezone string
+
+	// Proxy routes all traffic through a proxy server, e.g. "socks5://127.0.0.1:1080".
+	Proxy string
+
+	// ExecPath is the Chrome executable to run. By default chromedp searches the usual install locations.
+	ExecPath string
+
+	// WindowWidth and WindowHeight set the window size, 1280x850 by default.
+	WindowWidth  int
+	WindowHeight int
+
+	// ProfileDir is the user data directory, os.UserConfigDir()/ChromeDriver/Profile by default.
+	ProfileDir string
+
+	// Flags are extra command line switches, without the leading dashes. A value of true passes a bare switch.
+	Flags map[string]interface{}
+
+	// DownloadDir is where files the browser downloads are saved. By default downloads use Chrome's own setting.
+	DownloadDir string
+}
 
 // Creates a single new browser instance, returned in 2 parts. The first part is the context, which manages the browser actions and states and must be passed to any function used in this package. The second part is a cancel function, which can be used to close the browser instance.
 //
@@ -52,56 +72,166 @@ var bypassScript = `(function(w, n, wn) {
 // - Fresh determines if the browser should start with a clean profile.
 //
 // - EnableBypass determines if the browser should bypass potential bot detection.
+//
+// Use NewBrowser for the remaining options.
 func CreateBrowser(headless, fresh, enableBypass bool) (context.Context, context.CancelFunc, error) {
-	if !fileExists(roamingDir() + "/ChromeDriver") {
-		err := os.Mkdir(roamingDir()+"/ChromeDriver", 0755)
+	return NewBrowser(BrowserOptions{Headless: headless, Fresh: fresh, Bypass: enableBypass})
+}
+
+// NewBrowser starts a browser configured by opts. The returned context and cancel function work the same as those from CreateBrowser.
+func NewBrowser(opts BrowserOptions) (context.Context, context.CancelFunc, error) {
+	profileDir := opts.ProfileDir
+	if profileDir == "" {
+		profileDir = roamingDir() + "/ChromeDriver/Profile"
+	}
+
+	if opts.Fresh && fileExists(profileDir) {
+		err := os.RemoveAll(profileDir)
 		if err != nil {
-			return nil, nil, fmt.Errorf("error creating Chrome data directory: %v", err)
+			return nil, nil, fmt.Errorf("error removing existing Chrome data directory: %v", err)
 		}
 	}
 
-	if fresh && fileExists(roamingDir()+"/ChromeDriver/Profile") {
-		err := os.RemoveAll(roamingDir() + "/ChromeDriver/Profile")
+	if !fileExists(filepath.Dir(profileDir)) {
+		err := os.MkdirAll(filepath.Dir(profileDir), 0755)
 		if err != nil {
-			return nil, nil, fmt.Errorf("error removing existing Chrome data directory: %v", err)
+			return nil, nil, fmt.Errorf("error creating Chrome data directory: %v", err)
 		}
 	}
 
-	opts := []chromedp.ExecAllocatorOption{
-		chromedp.WindowSize(1280, 850),
-		chromedp.UserDataDir(roamingDir() + "/ChromeDriver/Profile"),
+	width, height := opts.WindowWidth, opts.WindowHeight
+	if width == 0 || height == 0 {
+		width, height = 1280, 850
+	}
+
+	allocOpts := []chromedp.ExecAllocatorOption{
+		chromedp.WindowSize(width, height),
+		chromedp.UserDataDir(profileDir),
 		chromedp.Flag("profile-directory", "Profile"),
 	}
 
-	if headless {
-		opts = append(opts, chromedp.Headless)
+	if opts.Headless {
+		allocOpts = append(allocOpts, chromedp.Headless)
+	}
+	if opts.ExecPath != "" {
+		allocOpts = append(allocOpts, chromedp.ExecPath(opts.ExecPath))
+	}
+	if opts.UserAgent != "" {
+		allocOpts = append(allocOpts, chromedp.UserAgent(opts.UserAgent))
+	}
+	if opts.Proxy != "" {
+		allocOpts = append(allocOpts, chromedp.ProxyServer(opts.Proxy))
+	}
+	if opts.Locale != "" {
+		allocOpts = append(allocOpts, chromedp.Flag("lang", opts.Locale))
+	}
+	for name, value := range opts.Flags {
+		allocOpts = append(allocOpts, chromedp.Flag(name, value))
 	}
 
 	// Create a new execution context with the specified options.
-	allocCtx, _ := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), allocOpts...)
 
 	// Create a new Chromedp context using the allocator context
-	ctx, closeBrowser := chromedp.NewContext(allocCtx)
+	ctx, cancelCtx := chromedp.NewContext(allocCtx)
+	closeBrowser := func() {
+		cancelCtx()
+		cancelAlloc()
+	}
 
-	if enableBypass {
-		// Execute scripts on the new document to bypass potential limitations.
-		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
-			_, err := page.AddScriptToEvaluateOnNewDocument(bypassScript).Do(ctx)
-			return err
-		}))
-		if err != nil {
-			return nil, nil, fmt.Errorf("error adding bypass script: %v", err)
+	ctx, err := setupBrowser(ctx, opts)
+	if err != nil {
+		closeBrowser()
+		return nil, nil, err
+	}
+
+	return ctx, closeBrowser, nil
+}
+
+// ConnectBrowser attaches to a browser that is already running, such as a headless-shell container, instead of starting one. endpoint is its DevTools address, either the websocket URL (ws://host:9222/devtools/browser/...) or the HTTP address (http://host:9222), from which the websocket URL is looked up.
+//
+// The returned context and cancel function work the same as those from CreateBrowser, except that cancelling closes the tab that was opened rather than the remote browser.
+//
+// Options that are Chrome command line switches (ExecPath, Proxy, ProfileDir, Fresh, Headless, WindowWidth, WindowHeight and Flags) have no effect on a browser that is already running and are ignored. UserAgent is applied through DevTools instead.
+func ConnectBrowser(endpoint string, opts BrowserOptions) (context.Context, context.CancelFunc, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(context.Background(), endpoint)
+	ctx, cancelCtx := chromedp.NewContext(allocCtx)
+	closeBrowser := func() {
+		cancelCtx()
+		cancelAlloc()
+	}
+
+	// Running no actions connects and opens the tab, so an unreachable endpoint fails here
+	if err := chromedp.Run(ctx); err != nil {
+		closeBrowser()
+		return nil, nil, fmt.Errorf("error connecting to browser at %s: %v", endpoint, err)
+	}
+
+	if opts.UserAgent != "" {
+		if err := chromedp.Run(ctx, emulation.SetUserAgentOverride(opts.UserAgent)); err != nil {
+			closeBrowser()
+			return nil, nil, fmt.Errorf("error setting user agent: %v", err)
 		}
 	}
 
-	Navigate(ctx, "about:blank")
+	ctx, err := setupBrowser(ctx, opts)
+	if err != nil {
+		closeBrowser()
+		return nil, nil, err
+	}
 
 	return ctx, closeBrowser, nil
 }
 
+// downloadKey holds the absolute BrowserOptions.DownloadDir in a browser's context
+type downloadKey struct{}
+
+// setupBrowser applies the options that are set over DevTools rather than on the command line, then opens a blank page. The returned context remembers the download directory so DownloadFile can put it back.
+func setupBrowser(ctx context.Context, opts BrowserOptions) (context.Context, error) {
+	if opts.Bypass {
+		if err := applyStealth(ctx, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Locale != "" {
+		err := chromedp.Run(ctx,
+			emulation.SetLocaleOverride().WithLocale(opts.Locale),
+			network.SetExtraHTTPHeaders(network.Headers{"Accept-Language": opts.Locale}),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error setting locale: %v", err)
+		}
+	}
+
+	if opts.Timezone != "" {
+		if err := chromedp.Run(ctx, emulation.SetTimezoneOverride(opts.Timezone)); err != nil {
+			return nil, fmt.Errorf("error setting timezone: %v", err)
+		}
+	}
+
+	if opts.DownloadDir != "" {
+		dir, err := filepath.Abs(opts.DownloadDir)
+		if err == nil {
+			err = os.MkdirAll(dir, 0755)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error creating download directory: %v", err)
+		}
+		err = chromedp.Run(ctx, browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllow).WithDownloadPath(dir))
+		if err != nil {
+			return nil, fmt.Errorf("error setting download directory: %v", err)
+		}
+		ctx = context.WithValue(ctx, downloadKey{}, dir)
+	}
+
+	Navigate(ctx, "about:blank")
+	return ctx, nil
+}
+
 // InputText sets text on an input element and optionally triggers input-related events.
 //
-// - selector specifies the CSS selector of the input element to target.
+// - selector specifies the selector of the input element to target.
 //
 // - input is the string value to be set on the targeted input element.
 //
@@ -113,22 +243,34 @@ func CreateBrowser(headless, fresh, enableBypass bool) (context.Context, context
 //     true triggers both 'input' and 'change' events to simulate more natural user interaction.
 //     false sets the value without triggering these events.
 //
-// The function handles errors that may occur during the chromedp action execution and returns them.
+// In a context from Human the text is typed key by key instead, and useEval and useTrip are ignored.
+//
+// Errors during execution are logged, not returned.
 func InputText(ctx context.Context, selector string, input string, useEval bool, useTrip bool) {
+	selector = resolve(ctx, selector, "InputText")
+	if h := humanMode(ctx); h != nil {
+		if err := h.humanType(ctx, selector, input); err != nil {
+			log.Printf("failed to input text: %v", err)
+		}
+		return
+	}
+
 	var actions []chromedp.Action
 
 	if useEval {
-		setValueJS := fmt.Sprintf(`document.querySelector(%q).value = %q;`, selector, input)
-		actions = append(actions, chromedp.Evaluate(setValueJS, nil))
+		actions = append(actions, callOn(selector, `function(value) { this.value = value; }`, nil, input))
 	} else {
-		actions = append(actions, chromedp.SendKeys(selector, input))
+		sel, by := query(ctx, selector, false)
+		actions = append(actions, chromedp.SendKeys(sel, input, by))
 	}
 
 	if useTrip {
 		time.Sleep(500 * time.Millisecond)
-		dispatchInputJS := fmt.Sprintf(`document.querySelector(%q).dispatchEvent(new Event('input', { bubbles: true }));`, selector)
-		dispatchChangeJS := fmt.Sprintf(`document.querySelector(%q).dispatchEvent(new Event('change', { bubbles: true }));`, selector)
-		actions = append(actions, chromedp.Evaluate(dispatchInputJS, nil), chromedp.Evaluate(dispatchChangeJS, nil))
+		dispatchJS := `function() {
+			this.dispatchEvent(new Event('input', { bubbles: true }));
+			this.dispatchEvent(new Event('change', { bubbles: true }));
+		}`
+		actions = append(actions, callOn(selector, dispatchJS, nil))
 	}
 
 	if err := chromedp.Run(ctx, actions...); err != nil {
@@ -137,13 +279,13 @@ func InputText(ctx context.Context, selector string, input string, useEval bool,
 
 }
 
-// GetAttribute retrieves an attribute from a DOM element specified by a CSS selector.
+// GetAttribute retrieves an attribute from a DOM element specified by a selector.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
 // - attribute specifies the name of the attribute to be retrieved from the targeted element.
 //
-// - selector is the CSS selector used to locate the element from which the attribute should be retrieved.
+// - selector is the selector used to locate the element from which the attribute should be retrieved.
 //
 //   - useEval determines the method of attribute retrieval:
 //     true uses JavaScript evaluation to fetch the attribute, which allows accessing dynamically set attributes.
@@ -151,17 +293,18 @@ func InputText(ctx context.Context, selector string, input string, useEval bool,
 //
 // The function returns the attribute value as a string and an error if any issues occur during execution.
 func GetAttribute(ctx context.Context, attribute string, selector string, useEval bool) string {
+	selector = resolve(ctx, selector, "GetAttribute")
 	var res string
 	var action chromedp.Action
 
 	if useEval {
 		// Use JavaScript evaluation to fetch attribute
-		jsScript := fmt.Sprintf(`document.querySelector(`+"`%s`"+`).getAttribute(`+"`%s`"+`)`, selector, attribute)
-		action = chromedp.Evaluate(jsScript, &res)
+		action = callOn(selector, `function(name) { return this.getAttribute(name) ?? ''; }`, &res, attribute)
 	} else {
 		// Use AttributeValue to fetch the attribute directly
 		var present bool
-		action = chromedp.AttributeValue(selector, attribute, &res, &present, chromedp.AtLeast(0))
+		sel, by := query(ctx, selector, false)
+		action = chromedp.AttributeValue(sel, attribute, &res, &present, by, chromedp.AtLeast(0))
 	}
 
 	// Execute the appropriate chromedp action
@@ -173,43 +316,50 @@ func GetAttribute(ctx context.Context, attribute string, selector string, useEva
 	return res
 }
 
-// GetText retrieves the text content from a DOM element specified by a CSS selector.
+// GetText retrieves the text content from a DOM element specified by a selector.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
-// - selector is the CSS selector used to locate the element from which the text should be retrieved.
+// - selector is the selector used to locate the element from which the text should be retrieved.
 //
 // The function returns the text content as a string and an error if any issues occur during execution.
 func GetText(ctx context.Context, selector string) string {
+	selector = resolve(ctx, selector, "GetText")
 	var value string
-	if err := chromedp.Run(ctx, chromedp.Text(selector, &value, chromedp.AtLeast(0))); err != nil {
+	sel, by := query(ctx, selector, false)
+	if err := chromedp.Run(ctx, chromedp.Text(sel, &value, by, chromedp.AtLeast(0))); err != nil {
 		log.Printf("failed to get text from selector %q: %v", selector, err)
 		return ""
 	}
 	return value
 }
 
-// Click performs a click action on a DOM element specified by a CSS selector.
+// Click performs a click action on a DOM element specified by a selector.
 // It can execute the click either directly or through JavaScript evaluation based on the useEval flag.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
-// - selector is the CSS selector used to locate the element to be clicked.
+// - selector is the selector used to locate the element to be clicked.
 //
 //   - useEval determines the method of executing the click:
 //     true uses JavaScript evaluation to trigger the click, which can bypass certain DOM event listeners.
 //     false uses the standard Chromedp click action, which simulates a more realistic user interaction.
 //
+// In a context from Human the pointer moves to the element and clicks it like a person would, and useEval is ignored.
+//
 // Errors during execution are logged, not returned.
 func Click(ctx context.Context, selector string, useEval bool) {
+	selector = resolve(ctx, selector, "Click")
 	var err error
-	if useEval {
+	if h := humanMode(ctx); h != nil {
+		err = h.humanClick(ctx, selector)
+	} else if useEval {
 		// Perform click using JavaScript
-		jsClick := fmt.Sprintf(`document.querySelector("%s").click()`, selector)
-		err = chromedp.Run(ctx, chromedp.Evaluate(jsClick, nil))
+		err = CallOn(ctx, selector, `function() { this.click(); }`, nil)
 	} else {
 		// Perform click using chromedp's built-in function
-		err = chromedp.Run(ctx, chromedp.Click(selector, chromedp.NodeVisible))
+		sel, by := query(ctx, selector, false)
+		err = chromedp.Run(ctx, chromedp.Click(sel, by, chromedp.NodeVisible))
 	}
 
 	if err != nil {
@@ -217,32 +367,34 @@ func Click(ctx context.Context, selector string, useEval bool) {
 	}
 }
 
-// SetClass sets the class attribute of a DOM element specified by a CSS selector.
+// SetClass sets the class attribute of a DOM element specified by a selector.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
-// - selector is the CSS selector used to locate the element for which the class attribute is to be set.
+// - selector is the selector used to locate the element for which the class attribute is to be set.
 //
 // - newClasses is the new class string to be applied to the targeted element.
 //
 // Errors during execution are logged, not returned. This function directly manipulates the class attribute using JavaScript.
 func SetClass(ctx context.Context, selector string, newClasses string) {
-	code := fmt.Sprintf(`document.querySelector("%s").className = "%s"`, selector, newClasses)
-	if err := chromedp.Run(ctx, chromedp.Evaluate(code, nil)); err != nil {
+	selector = resolve(ctx, selector, "SetClass")
+	if err := CallOn(ctx, selector, `function(classes) { this.className = classes; }`, nil, newClasses); err != nil {
 		log.Printf("error setting class for selector %q: %v", selector, err)
 	}
 }
 
-// GetNodes retrieves all DOM nodes matching a specified CSS selector.
+// GetNodes retrieves all DOM nodes matching a specified selector.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
-// - selector is the CSS selector used to locate the elements from which nodes are to be retrieved.
+// - selector is the selector used to locate the elements from which nodes are to be retrieved.
 //
 // The function logs any errors that occur during execution and returns a slice of pointers to the cdp.Node objects.
 func GetNodes(ctx context.Context, selector string) []*cdp.Node {
+	selector = resolve(ctx, selector, "GetNodes")
 	var nodes []*cdp.Node
-	if err := chromedp.Run(ctx, chromedp.Nodes(selector, &nodes, chromedp.ByQueryAll)); err != nil {
+	sel, by := query(ctx, selector, true)
+	if err := chromedp.Run(ctx, chromedp.Nodes(sel, &nodes, by)); err != nil {
 		log.Printf("error retrieving nodes for selector %q: %v", selector, err)
 		return nil
 	}
@@ -266,33 +418,60 @@ func Navigate(ctx context.Context, url string) {
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
-// - selector is the CSS selector of the element to wait for.
+// - selector is the selector of the element to wait for.
 //
 // Errors during execution are logged.
 func WaitForElement(ctx context.Context, selector string) {
-	if err := chromedp.Run(ctx, chromedp.WaitReady(selector)); err != nil {
+	selector = resolve(ctx, selector, "WaitForElement")
+	sel, by := query(ctx, selector, false)
+	if err := chromedp.Run(ctx, chromedp.WaitReady(sel, by)); err != nil {
 		log.Printf("error waiting for element %q to be ready: %v", selector, err)
 	}
 }
 
-// ElementExists checks for the existence of a DOM element specified by a CSS selector within a timeout period.
+// ElementExists checks for the existence of a DOM element specified by a selector within a timeout period.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
-// - selector is the CSS selector used to locate the element.
+// - selector is the selector used to locate the element.
 //
 // - timeout is the maximum time in milliseconds to wait for the element to appear.
 //
 // Returns true if the element appears within the timeout, otherwise false. Errors during the process are logged.
 func ElementExists(ctx context.Context, selector string, timeout int64) bool {
 	st := time.Now()
+	sel, by := query(ctx, selector, true)
+	alts := alternatives(selector)
 
 	for {
+		// A cancelled context would only fail every check until the timeout
+		if ctx.Err() != nil {
+			return false
+		}
+
+		// Selector lists are checked without waiting so every alternative gets a look on each pass
+		if len(alts) > 1 {
+			i := firstPresent(ctx, alts)
+			timedOut := time.Since(st).Milliseconds() > timeout
+			// A fallback is taken once the primary has had its grace period, or the wait is over
+			if i == 0 || (i > 0 && (time.Since(st) >= fallbackGrace || timedOut)) {
+				if i > 0 {
+					recordFallback(ctx, "ElementExists", alts, i)
+				}
+				return true
+			}
+			if timedOut {
+				return false
+			}
+			time.Sleep(250 * time.Millisecond)
+			continue
+		}
+
 		tctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
 		defer cancel()
 
 		var nodes []*cdp.Node
-		if err := chromedp.Run(tctx, chromedp.Nodes(selector, &nodes, chromedp.ByQueryAll)); err != nil {
+		if err := chromedp.Run(tctx, chromedp.Nodes(sel, &nodes, by)); err != nil {
 			log.Printf("error checking existence for selector %q: %v", selector, err)
 		}
 
@@ -318,6 +497,17 @@ func Reload(ctx context.Context) {
 	}
 }
 
+// ClearCookies deletes every cookie in the browser, which signs out of all sites.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// Errors during execution are logged.
+func ClearCookies(ctx context.Context) {
+	if err := chromedp.Run(ctx, network.ClearBrowserCookies()); err != nil {
+		log.Printf("error clearing cookies: %v", err)
+	}
+}
+
 // GetSource retrieves the outer HTML of the entire document.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
@@ -339,21 +529,21 @@ func GetSource(ctx context.Context) string {
 	return res
 }
 
-// SubmitForm submits a form identified by a CSS selector.
+// SubmitForm submits a form identified by a selector.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
-// - formSelector is the CSS selector of the form to submit.
+// - formSelector is the selector of the form to submit.
 //
 // Errors during execution are logged.
 func SubmitForm(ctx context.Context, formSelector string) {
-	code := fmt.Sprintf(`document.querySelector("%s").submit()`, formSelector)
-	if err := chromedp.Run(ctx, chromedp.Evaluate(code, nil)); err != nil {
+	formSelector = resolve(ctx, formSelector, "SubmitForm")
+	if err := CallOn(ctx, formSelector, `function() { this.submit(); }`, nil); err != nil {
 		log.Printf("error submitting form %q: %v", formSelector, err)
 	}
 }
 
-// RunEval executes JavaScript code in the browser context and logs any errors.
+// RunEval executes JavaScript code in the browser context and logs any errors. Use Call instead when the script needs values from Go, so they are passed as arguments rather than pasted into the code.
 //
 // - ctx is the Chromedp context which manages the underlying browser actions and states.
 //
@@ -416,11 +606,137 @@ func CaptureScreenshot(ctx context.Context, filename string) {
 	}
 }
 
+// DownloadFile clicks the element that starts a download and waits for the browser to finish saving it.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - selector is the selector of the link or button that starts the download. Links are given a download attribute first so PDFs are saved instead of opened in the viewer.
+//
+// - dir is the directory the file is saved to. It is created if it does not exist.
+//
+// - timeout is the maximum time in milliseconds to wait for the download to complete.
+//
+// Returns the path of the downloaded file, named after the file name the site suggested.
+func DownloadFile(ctx context.Context, selector string, dir string, timeout int64) (string, error) {
+	selector = resolve(ctx, selector, "DownloadFile")
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("error resolving download directory: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating download directory: %v", err)
+	}
+
+	// The download path is browser-wide, so the one from BrowserOptions.DownloadDir is put back afterwards
+	defer restoreDownloads(ctx)
+
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sel, by := query(ctx, selector, false)
+
+	// Downloads are saved under their GUID; remember the suggested name to rename it afterwards
+	var mu sync.Mutex
+	names := map[string]string{}
+	done := make(chan string, 1)
+	failed := make(chan string, 1)
+	chromedp.ListenTarget(lctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *browser.EventDownloadWillBegin:
+			mu.Lock()
+			names[ev.GUID] = ev.SuggestedFilename
+			mu.Unlock()
+		case *browser.EventDownloadProgress:
+			switch ev.State {
+			case browser.DownloadProgressStateCompleted:
+				select {
+				case done <- ev.GUID:
+				default:
+				}
+			case browser.DownloadProgressStateCanceled:
+				select {
+				case failed <- ev.GUID:
+				default:
+				}
+			}
+		}
+	})
+
+	err = chromedp.Run(ctx,
+		browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName).WithDownloadPath(dir).WithEventsEnabled(true),
+		chromedp.WaitVisible(sel, by),
+		callOn(selector, `function() { if (this.tagName === 'A') { this.setAttribute('download', ''); } }`, nil),
+		chromedp.Click(sel, by, chromedp.NodeVisible),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error starting download from %q: %v", selector, err)
+	}
+
+	var guid string
+	select {
+	case guid = <-done:
+	case <-failed:
+		return "", fmt.Errorf("download from %q was canceled", selector)
+	case <-time.After(time.Duration(timeout) * time.Millisecond):
+		return "", fmt.Errorf("download from %q did not complete within %d ms", selector, timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	path := filepath.Join(dir, guid)
+	mu.Lock()
+	name := filepath.Base(names[guid])
+	mu.Unlock()
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		return path, nil
+	}
+
+	target := filepath.Join(dir, name)
+	for i := 1; fileExists(target); i++ {
+		ext := filepath.Ext(name)
+		target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+	}
+	if err := os.Rename(path, target); err != nil {
+		return path, nil
+	}
+	return target, nil
+}
+
+// restoreDownloads returns downloads to BrowserOptions.DownloadDir, or to Chrome's own setting when none was given
+func restoreDownloads(ctx context.Context) {
+	// Restored even when the download was cut short by ctx being cancelled
+	ctx = context.WithoutCancel(ctx)
+	action := browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorDefault)
+	if dir, ok := ctx.Value(downloadKey{}).(string); ok {
+		action = browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllow).WithDownloadPath(dir)
+	}
+	if err := chromedp.Run(ctx, action); err != nil {
+		log.Printf("error restoring download directory: %v", err)
+	}
+}
+
 // Wait pauses the current goroutine for the specified duration in milliseconds.
 func Wait(durationMs int) {
 	time.Sleep(time.Duration(durationMs) * time.Millisecond)
 }
 
+// Pause waits for a duration unless the context is cancelled first. Use it instead of Wait inside a flow, so a cancelled run stops at once.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - d is how long to wait.
+//
+// Returns ctx's error if it is cancelled before d has passed.
+func Pause(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
 func roamingDir() string {
 	roaming, _ := os.UserConfigDir()
 	return roaming
@@ -448,6 +764,51 @@ func fileExists(path string) bool {
 //
 // - useTLS specifies whether to use TLS (secure) or not.
 func GetCodeFromImap(emailServer, emailAddress, password, emailSubject, delimPart1, delimPart2 string, useTLS bool) string {
+	code, _, err := latestCode(emailServer, emailAddress, password, emailSubject, delimPart1, delimPart2, useTLS)
+	if err != nil {
+		log.Print(err)
+		return ""
+	}
+	return code
+}
+
+// WaitCodeFromImap polls an IMAP server until an email with the specified subject arrives after since, then extracts the code from it as GetCodeFromImap does. Use it instead of sleeping while a verification email is on its way, since GetCodeFromImap alone would return the code of an older email.
+//
+// - ctx is the context whose cancellation stops the polling.
+//
+// - since is when the email was requested; emails the server received earlier are ignored.
+//
+// - timeout is the maximum time in milliseconds to wait for the email.
+//
+// The remaining parameters are those of GetCodeFromImap. Returns a *TimeoutError if no new email arrives in time, or ctx's error if it is cancelled first.
+func WaitCodeFromImap(ctx context.Context, since time.Time, timeout int64, emailServer, emailAddress, password, emailSubject, delimPart1, delimPart2 string, useTLS bool) (string, error) {
+	var code string
+	// Each check logs in again, so the mail server is asked less often than the page
+	err := waitEvery(ctx, timeout, imapPollInterval, fmt.Sprintf("email %q", emailSubject), func(ctx context.Context) (bool, error) {
+		c, received, err := latestCode(emailServer, emailAddress, password, emailSubject, delimPart1, delimPart2, useTLS)
+		if err != nil {
+			return false, err
+		}
+		// The mail server's clock may run a little behind ours
+		if received.Before(since.Add(-imapClockSlack)) {
+			return false, fmt.Errorf("latest email %q was received %s, before it was requested", emailSubject, received.Format(time.RFC3339))
+		}
+		code = c
+		return code != "", nil
+	})
+	return code, err
+}
+
+const (
+	// imapPollInterval is how often WaitCodeFromImap checks the mailbox
+	imapPollInterval = 3 * time.Second
+
+	// imapClockSlack is how far the mail server's receive time may trail our clock
+	imapClockSlack = 30 * time.Second
+)
+
+// latestCode extracts the code from the most recent email with the subject and reports when the server received it
+func latestCode(emailServer, emailAddress, password, emailSubject, delimPart1, delimPart2 string, useTLS bool) (string, time.Time, error) {
 	var c *client.Client
 	var err error
 
@@ -458,23 +819,20 @@ func GetCodeFromImap(emailServer, emailAddress, password, emailSubject, delimPar
 		c, err = client.Dial(emailServer + ":143")
 	}
 	if err != nil {
-		log.Printf("error connecting to IMAP server: %v", err)
-		return ""
+		return "", time.Time{}, fmt.Errorf("error connecting to IMAP server: %v", err)
 	}
 	defer c.Logout()
 
 	// Login with provided credentials
 	err = c.Login(emailAddress, password)
 	if err != nil {
-		log.Printf("error logging into IMAP server: %v", err)
-		return ""
+		return "", time.Time{}, fmt.Errorf("error logging into IMAP server: %v", err)
 	}
 
 	// Select INBOX
 	_, err = c.Select("INBOX", false)
 	if err != nil {
-		log.Printf("error selecting INBOX: %v", err)
-		return ""
+		return "", time.Time{}, fmt.Errorf("error selecting INBOX: %v", err)
 	}
 
 	// Search for emails with the specified subject
@@ -482,12 +840,10 @@ func GetCodeFromImap(emailServer, emailAddress, password, emailSubject, delimPar
 	criteria.Header.Add("Subject", emailSubject)
 	ids, err := c.Search(criteria)
 	if err != nil {
-		log.Printf("error searching emails: %v", err)
-		return ""
+		return "", time.Time{}, fmt.Errorf("error searching emails: %v", err)
 	}
 	if len(ids) == 0 {
-		log.Printf("no emails found with subject: %s", emailSubject)
-		return "" // No emails found
+		return "", time.Time{}, fmt.Errorf("no emails found with subject: %s", emailSubject)
 	}
 
 	// Get the most recent email with the specified subject
@@ -498,36 +854,35 @@ func GetCodeFromImap(emailServer, emailAddress, password, emailSubject, delimPar
 	section.Peek = true
 	messages := make(chan *imap.Message, 1)
 	go func() {
-		c.Fetch(seqSet, []imap.FetchItem{section.FetchItem()}, messages)
+		c.Fetch(seqSet, []imap.FetchItem{section.FetchItem(), imap.FetchInternalDate}, messages)
 	}()
 
 	// Read the message body
 	msg := <-messages
 	if msg == nil {
-		log.Printf("no message found with subject: %s", emailSubject)
-		return "" // No message found
+		return "", time.Time{}, fmt.Errorf("no message found with subject: %s", emailSubject)
 	}
 	r := msg.GetBody(&section)
+	if r == nil {
+		return "", time.Time{}, fmt.Errorf("no body in email with subject: %s", emailSubject)
+	}
 	body, err := io.ReadAll(r)
 	if err != nil {
-		log.Printf("error reading email body: %v", err)
-		return ""
+		return "", time.Time{}, fmt.Errorf("error reading email body: %v", err)
 	}
 
 	// Split the body to find the verification code
 	part1 := strings.Split(string(body), delimPart1)
 	if len(part1) < 2 {
-		log.Printf("delimiter %q not found in body", delimPart1)
-		return ""
+		return "", msg.InternalDate, fmt.Errorf("delimiter %q not found in body", delimPart1)
 	}
 	part2 := strings.Split(part1[1], delimPart2)
 	if len(part2) < 1 {
-		log.Printf("delimiter %q not found after first split", delimPart2)
-		return ""
+		return "", msg.InternalDate, fmt.Errorf("delimiter %q not found after first split", delimPart2)
 	}
 	code := strings.TrimSpace(part2[0])
 
-	return code
+	return code, msg.InternalDate, nil
 }
 
 // SavePageSource retrieves the HTML source of the current page and saves it to a file named source.html in the current directory.
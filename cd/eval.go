@@ -0,0 +1,91 @@
+package cd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// Call runs a JavaScript function in the page. Arguments are serialized to JSON and passed to the function as call arguments, so they never become part of the script and need no quoting.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - fn is the function declaration, e.g. `function(a, b) { return a + b; }`.
+//
+// - res receives the JSON-decoded return value. Pass nil to ignore it.
+//
+// - args are the arguments passed to fn.
+//
+// Returns an error if the function throws or the result cannot be decoded into res.
+func Call(ctx context.Context, fn string, res interface{}, args ...interface{}) error {
+	return chromedp.Run(ctx, call(fn, res, args...))
+}
+
+// CallOn runs a JavaScript function with this bound to the first element matching a selector. Arguments and results are handled as in Call.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - selector is the selector of the element fn is called on.
+//
+// - fn is the function declaration, e.g. `function(name) { return this.getAttribute(name); }`.
+//
+// - res receives the JSON-decoded return value. Pass nil to ignore it.
+//
+// - args are the arguments passed to fn.
+//
+// Returns an error if no element matches, the function throws or the result cannot be decoded into res.
+func CallOn(ctx context.Context, selector string, fn string, res interface{}, args ...interface{}) error {
+	return chromedp.Run(ctx, callOn(selector, fn, res, args...))
+}
+
+// call is the action behind Call
+func call(fn string, res interface{}, args ...interface{}) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var global *runtime.RemoteObject
+		if err := chromedp.Evaluate(`globalThis`, &global).Do(ctx); err != nil {
+			return fmt.Errorf("error getting the page's global object: %v", err)
+		}
+		defer release(ctx, global)
+		return chromedp.CallFunctionOn(fn, res, onObject(global.ObjectID), args...).Do(ctx)
+	})
+}
+
+// callOn is the action behind CallOn
+func callOn(selector string, fn string, res interface{}, args ...interface{}) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		el, err := element(ctx, selector)
+		if err != nil {
+			return err
+		}
+		if el.ObjectID == "" {
+			return fmt.Errorf("no element matches selector %q", selector)
+		}
+		defer release(ctx, el)
+		return chromedp.CallFunctionOn(fn, res, onObject(el.ObjectID), args...).Do(ctx)
+	})
+}
+
+// element finds the first element matching the selector in the context's frame. The object id is empty when nothing matches.
+func element(ctx context.Context, selector string) (*runtime.RemoteObject, error) {
+	var el *runtime.RemoteObject
+	err := chromedp.Run(ctx, call(locateJS, &el, framesJSON(frameScope(ctx)), stepsJSON(parseSteps(selector)), false, nil))
+	if err != nil {
+		return nil, fmt.Errorf("error locating selector %q: %v", selector, err)
+	}
+	return el, nil
+}
+
+// release frees a remote object so the page can garbage collect it
+func release(ctx context.Context, obj *runtime.RemoteObject) {
+	if obj != nil && obj.ObjectID != "" {
+		chromedp.Run(ctx, runtime.ReleaseObject(obj.ObjectID))
+	}
+}
+
+func onObject(id runtime.RemoteObjectID) chromedp.CallOption {
+	return func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+		return p.WithObjectID(id)
+	}
+}
@@ -0,0 +1,147 @@
+package cd
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listSep joins the alternatives of a selector list. It cannot appear in a CSS selector or XPath expression.
+const listSep = "\x1e"
+
+// Any returns a selector list: the helpers in this package try each alternative in order and use the first one present on the page. When an alternative other than the first is used it is recorded, see RecordFallbacks, so a broken primary selector can be fixed before the fallbacks rot too.
+func Any(selectors ...string) string {
+	return strings.Join(selectors, listSep)
+}
+
+// Fallback records a selector list whose primary alternative was missing.
+type Fallback struct {
+	Caller    string   // the function that called the helper, e.g. main.getPowerBill
+	Step      string   // the helper, e.g. GetText
+	Selectors []string // every alternative, primary first
+	Index     int      // the alternative that matched
+}
+
+func (f Fallback) String() string {
+	return fmt.Sprintf("%s: primary `%s` missing in %s, fallback %d used (`%s`)", f.Caller, f.Selectors[0], f.Step, f.Index, f.Selectors[f.Index])
+}
+
+// fallbackGrace is how long the primary alternative is given to appear once a fallback is present, so a primary that renders a moment after the fallback is still the one used
+var fallbackGrace = time.Second
+
+type fallbackKey struct{}
+
+// fallbackLog collects the fallbacks used with one context
+type fallbackLog struct {
+	mu   sync.Mutex
+	used []Fallback
+}
+
+// RecordFallbacks returns a context whose helpers record the fallbacks they use, along with the contexts derived from it such as Frame scopes. Each call starts a log of its own, so runs and tabs do not see each other's fallbacks. Contexts without a log record nothing.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+func RecordFallbacks(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fallbackKey{}, &fallbackLog{})
+}
+
+// TakeFallbacks returns the fallbacks recorded for ctx since the last call and clears them.
+//
+// - ctx is a context returned by RecordFallbacks, or derived from one.
+func TakeFallbacks(ctx context.Context) []Fallback {
+	log, _ := ctx.Value(fallbackKey{}).(*fallbackLog)
+	if log == nil {
+		return nil
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	out := log.used
+	log.used = nil
+	return out
+}
+
+func alternatives(selector string) []string {
+	return strings.Split(selector, listSep)
+}
+
+// resolve picks the alternative of a selector list to use. The primary wins as soon as it is present; a fallback only once the primary has had fallbackGrace to show up. When no alternative is present the primary is returned so the helper waits for it and reports the failure as it always has.
+func resolve(ctx context.Context, selector, step string) string {
+	alts := alternatives(selector)
+	if len(alts) == 1 {
+		return selector
+	}
+	st := time.Now()
+	for {
+		i := firstPresent(ctx, alts)
+		if i <= 0 {
+			return alts[0]
+		}
+		if time.Since(st) >= fallbackGrace || ctx.Err() != nil {
+			recordFallback(ctx, step, alts, i)
+			return alts[i]
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// firstPresent returns the index of the first alternative that matches right now, or -1 when none does
+func firstPresent(ctx context.Context, alts []string) int {
+	for i, alt := range alts {
+		if present(ctx, alt) {
+			return i
+		}
+	}
+	return -1
+}
+
+// present checks whether a selector matches right now, without waiting
+func present(ctx context.Context, selector string) bool {
+	el, err := element(ctx, selector)
+	if err != nil || el.ObjectID == "" {
+		return false
+	}
+	release(ctx, el)
+	return true
+}
+
+func recordFallback(ctx context.Context, step string, alts []string, index int) {
+	log, _ := ctx.Value(fallbackKey{}).(*fallbackLog)
+	if log == nil {
+		return
+	}
+	f := Fallback{Caller: callerOutsidePackage(), Step: step, Selectors: alts, Index: index}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	// Waits resolve their selector on every poll; one record per use is enough
+	for _, seen := range log.used {
+		if seen.String() == f.String() {
+			return
+		}
+	}
+	log.used = append(log.used, f)
+}
+
+// packagePrefix is the qualified name prefix of this package's functions, e.g. "billburner/cd."
+var packagePrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	return name[:strings.LastIndex(name, ".")+1]
+}()
+
+// callerOutsidePackage names the first function on the stack that is not part of this package
+func callerOutsidePackage() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) {
+			return frame.Function
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAny(t *testing.T) {
+	tests := []struct {
+		name      string
+		selectors []string
+		want      []string
+	}{
+		{"single selector", []string{"#btnLogin"}, []string{"#btnLogin"}},
+		{"css and role", []string{"#btnLogin", Role("button", "Sign In")}, []string{"#btnLogin", `role=button[name="Sign In"]`}},
+		{"shadow path and xpath", []string{"pay-form >>> button", "//button[text()='Pay']", "text=Pay"}, []string{"pay-form >>> button", "//button[text()='Pay']", "text=Pay"}},
+		{"commas stay inside an alternative", []string{"h1, h2", "main"}, []string{"h1, h2", "main"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := Any(tt.selectors...)
+			if got := alternatives(list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alternatives(%q) = %q, want %q", list, got, tt.want)
+			}
+			// A single selector is used as is, without a look at the page
+			if len(tt.selectors) == 1 {
+				if got := resolve(context.Background(), list, "Click"); got != tt.selectors[0] {
+					t.Errorf("resolve(%q) = %q", list, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTakeFallbacks(t *testing.T) {
+	alts := alternatives(Any("#amount", ".amount", "text=Amount due"))
+	run := RecordFallbacks(context.Background())
+	scoped := context.WithValue(run, scopeKey{}, []frameSpec{{by: "name", value: "billing"}})
+	other := RecordFallbacks(context.Background())
+
+	recordFallback(run, "GetText", alts, 1)
+	recordFallback(scoped, "GetText", alts, 1) // a repeat through a frame scope of the same run
+	recordFallback(scoped, "Click", alts, 2)
+	recordFallback(other, "GetText", alts, 2)
+	recordFallback(context.Background(), "GetText", alts, 1) // not recorded anywhere
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		steps []string
+	}{
+		{"run and its frame scopes", run, []string{"GetText", "Click"}},
+		{"other run", other, []string{"GetText"}},
+		{"no log", context.Background(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TakeFallbacks(tt.ctx)
+			var steps []string
+			for _, f := range got {
+				steps = append(steps, f.Step)
+				if strings.HasPrefix(f.Caller, packagePrefix) {
+					t.Errorf("caller %s is inside the package", f.Caller)
+				}
+			}
+			if !reflect.DeepEqual(steps, tt.steps) {
+				t.Errorf("TakeFallbacks() steps = %q, want %q", steps, tt.steps)
+			}
+			if again := TakeFallbacks(tt.ctx); len(again) != 0 {
+				t.Errorf("second TakeFallbacks() = %v, want none", again)
+			}
+		})
+	}
+}
+
+func TestFallbackString(t *testing.T) {
+	f := Fallback{Caller: "main.getPowerBill", Step: "GetText", Selectors: []string{".amount", "text=Amount due"}, Index: 1}
+	want := "main.getPowerBill: primary `.amount` missing in GetText, fallback 1 used (`text=Amount due`)"
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
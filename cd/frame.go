@@ -0,0 +1,170 @@
+package cd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// Frames passed to Frame are given as a selector for the iframe element, in any of the strategies the helpers take, or with a prefix:
+//
+//   - name=<name> matches the iframe with that name attribute.
+//
+//   - url=<pattern> matches the iframe whose address matches the regular expression.
+const (
+	prefixFrameName = "name="
+	prefixFrameURL  = "url="
+)
+
+// frameSpec is a parsed frame
+type frameSpec struct {
+	by    string // selector, name or url
+	value string
+	steps []strategy
+}
+
+// jsFrame is a frameSpec as passed to locateJS
+type jsFrame struct {
+	By    string   `json:"by"`
+	Value string   `json:"value,omitempty"`
+	Steps []jsStep `json:"steps,omitempty"`
+}
+
+func parseFrame(frame string) frameSpec {
+	switch {
+	case strings.HasPrefix(frame, prefixFrameName):
+		return frameSpec{by: "name", value: strings.TrimPrefix(frame, prefixFrameName)}
+	case strings.HasPrefix(frame, prefixFrameURL):
+		return frameSpec{by: "url", value: strings.TrimPrefix(frame, prefixFrameURL)}
+	default:
+		return frameSpec{by: "selector", value: frame, steps: parseSteps(frame)}
+	}
+}
+
+func (f *frameSpec) json() *jsFrame {
+	if f == nil {
+		return nil
+	}
+	return &jsFrame{By: f.by, Value: f.value, Steps: stepsJSON(f.steps)}
+}
+
+func framesJSON(frames []frameSpec) []*jsFrame {
+	out := make([]*jsFrame, len(frames))
+	for i := range frames {
+		out[i] = frames[i].json()
+	}
+	return out
+}
+
+type scopeKey struct{}
+
+// frameScope lists the frames, outermost first, that the helpers look in for ctx
+func frameScope(ctx context.Context) []frameSpec {
+	frames, _ := ctx.Value(scopeKey{}).([]frameSpec)
+	return frames
+}
+
+// Frame returns a context whose helpers work inside an iframe of the current page. Scoping a context that is already scoped reaches nested frames.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - frame identifies the iframe: a selector for the iframe element, name=<name> or url=<pattern>.
+//
+// - timeout is the maximum time in milliseconds to wait for the frame to appear.
+//
+// Same-origin frames are reached through the page. Cross-site frames, which Chrome runs in a process of their own, are attached to over DevTools instead; the attachment lasts until ctx is cancelled, so scope a context once and reuse it rather than calling Frame in a loop. Cross-origin frames from the same site share the page's process but not its DOM, and cannot be reached.
+//
+// Returns an error if no matching frame appears within the timeout.
+func Frame(ctx context.Context, frame string, timeout int64) (context.Context, error) {
+	spec := parseFrame(frame)
+	scope := frameScope(ctx)
+	var pattern *regexp.Regexp
+	if spec.by == "url" {
+		var err error
+		if pattern, err = regexp.Compile(spec.value); err != nil {
+			return nil, fmt.Errorf("invalid frame URL pattern %q: %v", spec.value, err)
+		}
+	}
+
+	st := time.Now()
+	for {
+		if fctx, ok := scopeFrame(ctx, scope, spec, pattern); ok {
+			return fctx, nil
+		}
+		if time.Since(st).Milliseconds() > timeout {
+			return nil, fmt.Errorf("frame %q not found within %d ms", frame, timeout)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// scopeFrame scopes ctx to the frame when it is there. Frames the page's scripts can reach are scoped through the page, which finds the frame element again on every use. Other frames are looked for among the browser's frame targets, see frameTarget.
+func scopeFrame(ctx context.Context, scope []frameSpec, spec frameSpec, pattern *regexp.Regexp) (context.Context, bool) {
+	var frameID cdp.FrameID
+	var el *runtime.RemoteObject
+	err := chromedp.Run(ctx, call(locateJS, &el, framesJSON(scope), []jsStep{}, false, spec.json()))
+	if err == nil && el != nil && el.ObjectID != "" {
+		defer release(ctx, el)
+		var accessible bool
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			if err := chromedp.CallFunctionOn(`function() { return !!this.contentDocument; }`, &accessible, onObject(el.ObjectID)).Do(ctx); err != nil {
+				return err
+			}
+			node, err := dom.DescribeNode().WithObjectID(el.ObjectID).Do(ctx)
+			if err != nil {
+				return err
+			}
+			frameID = node.FrameID
+			return nil
+		}))
+		if err == nil && accessible {
+			return context.WithValue(ctx, scopeKey{}, append(scope[:len(scope):len(scope)], spec)), true
+		}
+	}
+
+	match := frameTarget(frameID, pattern)
+	if match == nil {
+		return nil, false
+	}
+	return attachFrame(ctx, match)
+}
+
+// frameTarget tells which target belongs to an out-of-process frame. Chrome gives the target the id of the frame the iframe element shows, which stays the same when the frame redirects, so that id is used once the element is found. Until then url= frames are matched by the target's current address. It returns nil when there is nothing to match on.
+func frameTarget(frameID cdp.FrameID, pattern *regexp.Regexp) func(t *target.Info) bool {
+	switch {
+	case frameID != "":
+		return func(t *target.Info) bool { return string(t.TargetID) == string(frameID) }
+	case pattern != nil:
+		return func(t *target.Info) bool { return pattern.MatchString(t.URL) }
+	}
+	return nil
+}
+
+// attachFrame attaches to the out-of-process frame whose target satisfies match
+func attachFrame(ctx context.Context, match func(t *target.Info) bool) (context.Context, bool) {
+	targets, err := chromedp.Targets(ctx)
+	if err != nil {
+		return nil, false
+	}
+	for _, t := range targets {
+		if t.Type != "iframe" || !match(t) {
+			continue
+		}
+		// The new context is released with ctx; cancelling it on its own would close the frame
+		fctx, _ := chromedp.NewContext(ctx, chromedp.WithTargetID(t.TargetID))
+		if err := chromedp.Run(fctx); err != nil {
+			continue
+		}
+		// The frame is the top document of its own target, so the scope starts over
+		return context.WithValue(fctx, scopeKey{}, []frameSpec(nil)), true
+	}
+	return nil, false
+}
@@ -0,0 +1,62 @@
+package cd
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
+)
+
+func TestParseFrame(t *testing.T) {
+	tests := []struct {
+		frame string
+		by    string
+		value string
+		steps int
+	}{
+		{"name=billing", "name", "billing", 0},
+		{`url=^https://pay\.example\.com/`, "url", `^https://pay\.example\.com/`, 0},
+		{"#paymentFrame", "selector", "#paymentFrame", 1},
+		{"checkout-app >>> iframe.card", "selector", "checkout-app >>> iframe.card", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.frame, func(t *testing.T) {
+			got := parseFrame(tt.frame)
+			if got.by != tt.by || got.value != tt.value || len(got.steps) != tt.steps {
+				t.Errorf("parseFrame(%q) = %+v, want by %s, value %q and %d steps", tt.frame, got, tt.by, tt.value, tt.steps)
+			}
+		})
+	}
+}
+
+func TestFrameTarget(t *testing.T) {
+	pattern := regexp.MustCompile(`^https://pay\.example\.com/`)
+	// The frame was loaded from pay.example.com and has since redirected to a sign-in page elsewhere
+	redirected := &target.Info{TargetID: "F1", Type: "iframe", URL: "https://login.example.net/authorize"}
+	other := &target.Info{TargetID: "F2", Type: "iframe", URL: "https://pay.example.com/widget"}
+
+	tests := []struct {
+		name    string
+		frameID cdp.FrameID
+		pattern *regexp.Regexp
+		want    map[*target.Info]bool
+	}{
+		{"frame element found", "F1", pattern, map[*target.Info]bool{redirected: true, other: false}},
+		{"only a url pattern", "", pattern, map[*target.Info]bool{redirected: false, other: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := frameTarget(tt.frameID, tt.pattern)
+			for info, want := range tt.want {
+				if got := match(info); got != want {
+					t.Errorf("match(%s) = %v, want %v", info.URL, got, want)
+				}
+			}
+		})
+	}
+
+	if frameTarget("", nil) != nil {
+		t.Error("frameTarget without an id or pattern matches something")
+	}
+}
@@ -0,0 +1,244 @@
+package cd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/chromedp"
+)
+
+// HumanOptions tunes the human input mode started by Human. Zero fields take the defaults.
+type HumanOptions struct {
+	// MinKeyDelay and MaxKeyDelay bound the random pause after each keystroke, 60 to 180 ms by default.
+	MinKeyDelay time.Duration
+	MaxKeyDelay time.Duration
+
+	// MinMoveTime and MaxMoveTime bound how long the pointer takes to travel to an element, 250 to 700 ms by default.
+	MinMoveTime time.Duration
+	MaxMoveTime time.Duration
+
+	// MinThinkTime and MaxThinkTime bound the pause between reaching an element and acting on it, 150 to 600 ms by default.
+	MinThinkTime time.Duration
+	MaxThinkTime time.Duration
+}
+
+// human is the state of the human input mode kept in a context
+type human struct {
+	opts HumanOptions
+
+	mu   sync.Mutex
+	rnd  *rand.Rand
+	x, y float64 // where the pointer was left
+}
+
+type humanKey struct{}
+
+// Human returns a context in which InputText and Click act like a person: elements are scrolled into view, the pointer moves to them along a curve, clicks press and release a real mouse button, and text is typed one key at a time with uneven pauses, between a focus and a blur of the field. The useEval and useTrip arguments of those helpers are ignored in this mode.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - opts tunes the timing.
+func Human(ctx context.Context, opts HumanOptions) context.Context {
+	defaultDuration(&opts.MinKeyDelay, &opts.MaxKeyDelay, 60*time.Millisecond, 180*time.Millisecond)
+	defaultDuration(&opts.MinMoveTime, &opts.MaxMoveTime, 250*time.Millisecond, 700*time.Millisecond)
+	defaultDuration(&opts.MinThinkTime, &opts.MaxThinkTime, 150*time.Millisecond, 600*time.Millisecond)
+	h := &human{opts: opts, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	return context.WithValue(ctx, humanKey{}, h)
+}
+
+func defaultDuration(lo, hi *time.Duration, defLo, defHi time.Duration) {
+	if *lo == 0 && *hi == 0 {
+		*lo, *hi = defLo, defHi
+	}
+	if *hi < *lo {
+		*hi = *lo
+	}
+}
+
+// IsHuman reports whether ctx is in the human input mode started by Human
+func IsHuman(ctx context.Context) bool {
+	return humanMode(ctx) != nil
+}
+
+// humanMode returns the human input state of ctx, or nil when the mode is off
+func humanMode(ctx context.Context) *human {
+	h, _ := ctx.Value(humanKey{}).(*human)
+	return h
+}
+
+// between picks a random duration in [lo, hi]
+func (h *human) between(lo, hi time.Duration) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(h.rnd.Int63n(int64(hi-lo)+1))
+}
+
+func (h *human) float() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rnd.Float64()
+}
+
+// box is an element's position in the top-level viewport
+type box struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// scrollIntoViewJS centers the element in view and returns its box, offset by any same-origin frames it is nested in
+const scrollIntoViewJS = `function() {
+	this.scrollIntoView({ block: 'center', inline: 'center' });
+	const r = this.getBoundingClientRect();
+	let x = r.left, y = r.top;
+	for (let w = this.ownerDocument.defaultView; w && w.frameElement; w = w.parent) {
+		const f = w.frameElement.getBoundingClientRect();
+		x += f.left + w.frameElement.clientLeft;
+		y += f.top + w.frameElement.clientTop;
+	}
+	return { x: x, y: y, width: r.width, height: r.height };
+}`
+
+// reach scrolls the element into view and moves the pointer onto it, returning the point it stopped at
+func (h *human) reach(ctx context.Context, selector string) (float64, float64, error) {
+	sel, by := query(ctx, selector, false)
+	if err := chromedp.Run(ctx, chromedp.WaitVisible(sel, by)); err != nil {
+		return 0, 0, err
+	}
+
+	var b box
+	if err := CallOn(ctx, selector, scrollIntoViewJS, &b); err != nil {
+		return 0, 0, err
+	}
+	// Let smooth scrolling settle before aiming
+	if err := Pause(ctx, h.between(80*time.Millisecond, 200*time.Millisecond)); err != nil {
+		return 0, 0, err
+	}
+
+	// Aim somewhere near the middle, not dead center
+	tx := b.X + b.Width*(0.3+0.4*h.float())
+	ty := b.Y + b.Height*(0.3+0.4*h.float())
+	if err := h.move(ctx, tx, ty); err != nil {
+		return 0, 0, err
+	}
+	if err := Pause(ctx, h.between(h.opts.MinThinkTime, h.opts.MaxThinkTime)); err != nil {
+		return 0, 0, err
+	}
+	return tx, ty, nil
+}
+
+// move glides the pointer to (tx, ty) along a cubic Bézier curve with eased speed
+func (h *human) move(ctx context.Context, tx, ty float64) error {
+	h.mu.Lock()
+	sx, sy := h.x, h.y
+	h.mu.Unlock()
+
+	dist := math.Hypot(tx-sx, ty-sy)
+	steps := int(math.Max(12, math.Min(45, dist/15)))
+
+	// Control points sit off the straight line on one side, so the path bows the way a wrist turns
+	bow := (0.1 + 0.25*h.float()) * dist
+	if h.float() < 0.5 {
+		bow = -bow
+	}
+	nx, ny := 0.0, 0.0
+	if dist > 0 {
+		nx, ny = -(ty-sy)/dist, (tx-sx)/dist
+	}
+	c1x, c1y := sx+(tx-sx)*0.3+nx*bow, sy+(ty-sy)*0.3+ny*bow
+	c2x, c2y := sx+(tx-sx)*0.7+nx*bow*0.6, sy+(ty-sy)*0.7+ny*bow*0.6
+
+	stepTime := h.between(h.opts.MinMoveTime, h.opts.MaxMoveTime) / time.Duration(steps)
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		t = t * t * (3 - 2*t) // ease in and out
+		u := 1 - t
+		x := u*u*u*sx + 3*u*u*t*c1x + 3*u*t*t*c2x + t*t*t*tx
+		y := u*u*u*sy + 3*u*u*t*c1y + 3*u*t*t*c2y + t*t*t*ty
+		if err := chromedp.Run(ctx, chromedp.MouseEvent(input.MouseMoved, x, y)); err != nil {
+			return err
+		}
+		if err := Pause(ctx, stepTime); err != nil {
+			return err
+		}
+	}
+
+	h.mu.Lock()
+	h.x, h.y = tx, ty
+	h.mu.Unlock()
+	return nil
+}
+
+// click presses and releases the left button where the pointer is
+func (h *human) click(ctx context.Context, x, y float64) error {
+	if err := chromedp.Run(ctx, chromedp.MouseEvent(input.MousePressed, x, y, chromedp.ButtonLeft, chromedp.ClickCount(1))); err != nil {
+		return err
+	}
+	if err := Pause(ctx, h.between(50*time.Millisecond, 140*time.Millisecond)); err != nil {
+		return err
+	}
+	return chromedp.Run(ctx, chromedp.MouseEvent(input.MouseReleased, x, y, chromedp.ButtonLeft, chromedp.ClickCount(1)))
+}
+
+// humanClick is Click in human mode
+func (h *human) humanClick(ctx context.Context, selector string) error {
+	x, y, err := h.reach(ctx, selector)
+	if err != nil {
+		return err
+	}
+	return h.click(ctx, x, y)
+}
+
+// humanType is InputText in human mode: it clicks into the field, types each character and leaves the field
+func (h *human) humanType(ctx context.Context, selector string, text string) error {
+	x, y, err := h.reach(ctx, selector)
+	if err != nil {
+		return err
+	}
+	if err := h.click(ctx, x, y); err != nil {
+		return err
+	}
+
+	// The click normally focuses the field; make sure, and start from an empty value as select-all and typing over would
+	focusJS := `function() {
+		this.focus();
+		if ('value' in this && this.value !== '') {
+			this.value = '';
+			this.dispatchEvent(new Event('input', { bubbles: true }));
+		}
+	}`
+	if err := CallOn(ctx, selector, focusJS, nil); err != nil {
+		return err
+	}
+	if err := Pause(ctx, h.between(h.opts.MinThinkTime, h.opts.MaxThinkTime)); err != nil {
+		return err
+	}
+
+	n := 0
+	for _, r := range text {
+		n++
+		if err := chromedp.Run(ctx, chromedp.KeyEvent(string(r))); err != nil {
+			return fmt.Errorf("error typing character %d: %v", n, err)
+		}
+		delay := h.between(h.opts.MinKeyDelay, h.opts.MaxKeyDelay)
+		// Now and then a longer hesitation, as when looking at the keyboard
+		if h.float() < 0.08 {
+			delay += h.between(200*time.Millisecond, 500*time.Millisecond)
+		}
+		if err := Pause(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	// Leaving the field fires change, since the value was typed
+	return CallOn(ctx, selector, `function() { this.blur(); }`, nil)
+}
@@ -0,0 +1,215 @@
+package cd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// Browser configures the browser the pool starts, as in NewBrowser. Its DevTools options, Bypass, Evasions, Locale, Timezone and DownloadDir, are also applied to each new tab.
+	Browser BrowserOptions
+
+	// Endpoint attaches the pool to a browser that is already running instead of starting one, as in ConnectBrowser. Closing the pool then disconnects from that browser rather than stopping it.
+	Endpoint string
+
+	// MaxTabs is how many tabs may be open at once, 4 by default. Acquire waits for a free slot when the pool is full.
+	MaxTabs int
+
+	// Incognito opens every tab in its own browser context, so tabs share no cookies, storage or cache.
+	Incognito bool
+
+	// HealthTimeout bounds the health check run on each new tab, 5 seconds by default.
+	HealthTimeout time.Duration
+}
+
+// Pool owns one browser and hands out its tabs so several flows can run at the same time.
+type Pool struct {
+	browser      context.Context
+	closeBrowser context.CancelFunc
+	opts         PoolOptions
+	slots        chan struct{}
+
+	mu     sync.Mutex
+	tabs   map[*Tab]struct{}
+	closed bool
+}
+
+// Tab is one tab handed out by a Pool. Pass Ctx to the functions in this package and call Release when done. Ctx records the fallback selectors used in the tab, see TakeFallbacks.
+type Tab struct {
+	Ctx context.Context
+
+	pool     *Pool
+	cancel   context.CancelFunc
+	once     sync.Once
+	acquired time.Time
+}
+
+// ErrPoolClosed is returned by Acquire once the pool has been closed.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// NewPool starts a browser, or connects to one when opts.Endpoint is set, and creates a pool of its tabs. Call Close to release the tabs and shut the browser down.
+//
+// Returns an error if the browser cannot be started or reached.
+func NewPool(opts PoolOptions) (*Pool, error) {
+	if opts.MaxTabs <= 0 {
+		opts.MaxTabs = 4
+	}
+	if opts.HealthTimeout == 0 {
+		opts.HealthTimeout = 5 * time.Second
+	}
+
+	var browser context.Context
+	var closeBrowser context.CancelFunc
+	var err error
+	if opts.Endpoint != "" {
+		browser, closeBrowser, err = ConnectBrowser(opts.Endpoint, opts.Browser)
+	} else {
+		browser, closeBrowser, err = NewBrowser(opts.Browser)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pool{
+		browser:      browser,
+		closeBrowser: closeBrowser,
+		opts:         opts,
+		slots:        make(chan struct{}, opts.MaxTabs),
+		tabs:         map[*Tab]struct{}{},
+	}, nil
+}
+
+// Acquire opens a new tab, waiting for a free slot if the pool is full.
+//
+// - ctx bounds the wait for a slot; it does not limit the life of the tab.
+//
+// Returns an error if the wait is cancelled, the pool is closed or the new tab fails its health check.
+func (p *Pool) Acquire(ctx context.Context) (*Tab, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("error waiting for a free tab: %v", ctx.Err())
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, ErrPoolClosed
+	}
+	p.mu.Unlock()
+
+	var ctxOpts []chromedp.ContextOption
+	if p.opts.Incognito {
+		ctxOpts = append(ctxOpts, chromedp.WithNewBrowserContext())
+	}
+	tabCtx, cancel := chromedp.NewContext(p.browser, ctxOpts...)
+	tab := &Tab{Ctx: tabCtx, pool: p, cancel: cancel, acquired: time.Now()}
+
+	// Running no actions opens the tab
+	if err := chromedp.Run(tabCtx); err != nil {
+		cancel()
+		<-p.slots
+		return nil, fmt.Errorf("error opening tab: %v", err)
+	}
+	tabCtx, err := setupBrowser(tabCtx, p.opts.Browser)
+	if err != nil {
+		cancel()
+		<-p.slots
+		return nil, err
+	}
+	// Each tab keeps its own fallback log, so concurrent flows report only their own
+	tab.Ctx = RecordFallbacks(tabCtx)
+	if err := tab.Healthy(p.opts.HealthTimeout); err != nil {
+		cancel()
+		<-p.slots
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.tabs[tab] = struct{}{}
+	p.mu.Unlock()
+	return tab, nil
+}
+
+// Release closes the tab and frees its slot. In incognito pools the tab's browser context is disposed along with its cookies. Releasing a tab more than once is harmless.
+func (t *Tab) Release() {
+	t.once.Do(func() {
+		t.cancel()
+		t.pool.mu.Lock()
+		delete(t.pool.tabs, t)
+		t.pool.mu.Unlock()
+		<-t.pool.slots
+	})
+}
+
+// Healthy checks that the tab still responds by evaluating a trivial script.
+//
+// - timeout is the maximum time to wait for the answer.
+//
+// Returns an error if the tab is closed, crashed or does not answer in time.
+func (t *Tab) Healthy(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(t.Ctx, timeout)
+	defer cancel()
+
+	var ok bool
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`true`, &ok)); err != nil || !ok {
+		return fmt.Errorf("tab is not responding: %v", err)
+	}
+	return nil
+}
+
+// TargetID returns the DevTools id of the tab.
+func (t *Tab) TargetID() target.ID {
+	if c := chromedp.FromContext(t.Ctx); c != nil && c.Target != nil {
+		return c.Target.TargetID
+	}
+	return ""
+}
+
+// Tabs lists the tabs currently handed out, oldest first.
+func (p *Pool) Tabs() []*Tab {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tabs := make([]*Tab, 0, len(p.tabs))
+	for tab := range p.tabs {
+		tabs = append(tabs, tab)
+	}
+	sort.Slice(tabs, func(i, j int) bool { return tabs[i].acquired.Before(tabs[j].acquired) })
+	return tabs
+}
+
+// Check runs a health check on every open tab and releases the ones that fail, so their slots can be reused. It returns how many tabs were released.
+func (p *Pool) Check() int {
+	released := 0
+	for _, tab := range p.Tabs() {
+		if err := tab.Healthy(p.opts.HealthTimeout); err != nil {
+			tab.Release()
+			released++
+		}
+	}
+	return released
+}
+
+// Close releases every tab, shuts the browser down and makes further Acquire calls fail. Closing a pool more than once is harmless.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+	for _, tab := range p.Tabs() {
+		tab.Release()
+	}
+	p.closeBrowser()
+}
@@ -0,0 +1,363 @@
+package cd
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// Selectors passed to the helpers in this package are CSS by default. A prefix picks another strategy:
+//
+//   - css=<selector> is an explicit CSS selector.
+//
+//   - xpath=<expression> is an XPath expression. Selectors starting with // are treated as XPath too.
+//
+//   - text=<text> matches the innermost visible element containing the text, ignoring case and extra whitespace. Quote the text, text="Sign In", to require an exact match.
+//
+//   - role=<role>[name="<name>"] matches an element by its ARIA role, explicit or implied by the tag, and optionally its accessible name.
+//
+//   - label=<text> matches the form control a <label> or aria-label with that text belongs to.
+//
+//   - testid=<id> matches the element with that data-testid attribute.
+//
+// Separating selectors with >>> descends into the open shadow root of the element matched so far, e.g. "payment-form >>> input[name=card]". The text, role and label strategies search open shadow roots on their own.
+//
+// The Text, XPath, Role, Label and TestID functions build these strings with the quoting done for you. To query inside an iframe, scope the context with Frame.
+const (
+	shadowSep = ">>>"
+
+	prefixCSS    = "css="
+	prefixXPath  = "xpath="
+	prefixText   = "text="
+	prefixRole   = "role="
+	prefixLabel  = "label="
+	prefixTestID = "testid="
+)
+
+// Text returns a selector for the element showing the given text exactly.
+func Text(text string) string {
+	return prefixText + strconv.Quote(text)
+}
+
+// XPath returns a selector for an XPath expression.
+func XPath(expr string) string {
+	return prefixXPath + expr
+}
+
+// Role returns a selector for an element with the ARIA role and, when name is not empty, the accessible name.
+func Role(role, name string) string {
+	if name == "" {
+		return prefixRole + role
+	}
+	return prefixRole + role + "[name=" + strconv.Quote(name) + "]"
+}
+
+// Label returns a selector for the form control labelled with the text.
+func Label(text string) string {
+	return prefixLabel + text
+}
+
+// TestID returns a selector for the element with the data-testid attribute.
+func TestID(id string) string {
+	return prefixTestID + id
+}
+
+// strategy is a parsed selector
+type strategy struct {
+	kind  string // css, xpath, text, role or label
+	value string
+	name  string // accessible name for role selectors
+	exact bool
+}
+
+func parseSelector(selector string) strategy {
+	switch {
+	case strings.HasPrefix(selector, prefixCSS):
+		return strategy{kind: "css", value: strings.TrimPrefix(selector, prefixCSS)}
+	case strings.HasPrefix(selector, prefixXPath):
+		return strategy{kind: "xpath", value: strings.TrimPrefix(selector, prefixXPath)}
+	case strings.HasPrefix(selector, "//"), strings.HasPrefix(selector, "(//"):
+		return strategy{kind: "xpath", value: selector}
+	case strings.HasPrefix(selector, prefixText):
+		text, exact := unquote(strings.TrimPrefix(selector, prefixText))
+		return strategy{kind: "text", value: text, exact: exact}
+	case strings.HasPrefix(selector, prefixRole):
+		role := strings.TrimPrefix(selector, prefixRole)
+		s := strategy{kind: "role", value: role}
+		if i := strings.Index(role, "[name="); i >= 0 && strings.HasSuffix(role, "]") {
+			s.value = role[:i]
+			s.name, s.exact = unquote(role[i+len("[name=") : len(role)-1])
+		}
+		return s
+	case strings.HasPrefix(selector, prefixLabel):
+		text, exact := unquote(strings.TrimPrefix(selector, prefixLabel))
+		return strategy{kind: "label", value: text, exact: exact}
+	case strings.HasPrefix(selector, prefixTestID):
+		id := strings.TrimPrefix(selector, prefixTestID)
+		return strategy{kind: "css", value: "[data-testid=" + strconv.Quote(id) + "]"}
+	default:
+		return strategy{kind: "css", value: selector}
+	}
+}
+
+// unquote strips Go-style quotes, reporting whether the text was quoted
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u, true
+		}
+		return s[1 : len(s)-1], true
+	}
+	return s, false
+}
+
+// parseSteps splits a selector on >>> into the steps that descend through shadow roots
+func parseSteps(selector string) []strategy {
+	parts := strings.Split(selector, shadowSep)
+	steps := make([]strategy, len(parts))
+	for i, part := range parts {
+		steps[i] = parseSelector(strings.TrimSpace(part))
+	}
+	return steps
+}
+
+// jsStep is a strategy as passed to locateJS
+type jsStep struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+	Exact bool   `json:"exact,omitempty"`
+}
+
+func stepsJSON(steps []strategy) []jsStep {
+	out := make([]jsStep, len(steps))
+	for i, s := range steps {
+		out[i] = jsStep{Kind: s.kind, Value: s.value, Name: s.name, Exact: s.exact}
+	}
+	return out
+}
+
+// query translates a selector into what chromedp's query actions take. all asks for every match rather than the first one.
+//
+// Plain CSS and XPath selectors in the top document go straight to chromedp. Everything else, including any selector used in a context scoped with Frame, is located by locateJS.
+func query(ctx context.Context, selector string, all bool) (string, chromedp.QueryOption) {
+	steps := parseSteps(selector)
+	if len(steps) == 1 && len(frameScope(ctx)) == 0 {
+		switch s := steps[0]; s.kind {
+		case "css":
+			if all {
+				return s.value, chromedp.ByQueryAll
+			}
+			return s.value, chromedp.ByQuery
+		case "xpath":
+			return s.value, chromedp.BySearch
+		}
+	}
+	expr := locateCall(frameScope(ctx), steps, all)
+	if !all {
+		return expr, chromedp.ByJSPath
+	}
+	return expr, byJSAll(expr)
+}
+
+// byJSAll is a query option for a JavaScript expression that evaluates to an array of elements, which chromedp.ByJSPath cannot take
+func byJSAll(expr string) chromedp.QueryOption {
+	return chromedp.ByFunc(func(ctx context.Context, n *cdp.Node) ([]cdp.NodeID, error) {
+		v, exp, err := runtime.Evaluate(expr).WithObjectGroup("console").Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if exp != nil {
+			return nil, exp
+		}
+		if v.ObjectID == "" {
+			return []cdp.NodeID{}, nil
+		}
+
+		props, _, _, exp, err := runtime.GetProperties(v.ObjectID).WithOwnProperties(true).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if exp != nil {
+			return nil, exp
+		}
+
+		var ids []cdp.NodeID
+		for _, prop := range props {
+			if _, err := strconv.Atoi(prop.Name); err != nil || prop.Value == nil || prop.Value.ObjectID == "" {
+				continue
+			}
+			id, err := dom.RequestNode(prop.Value.ObjectID).Do(ctx)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	})
+}
+
+// locateCall is an expression calling locateJS, for chromedp's query actions which take an expression rather than a function. The arguments are JSON encoded, which makes them valid JavaScript literals.
+func locateCall(frames []frameSpec, steps []strategy, all bool) string {
+	args, _ := json.Marshal([]interface{}{framesJSON(frames), stepsJSON(steps), all, nil})
+	return "(" + locateJS + ")(..." + string(args) + ")"
+}
+
+// locateJS finds elements in the page. It walks down the scoped frames, then through the shadow roots named by the steps, and resolves the last step with its strategy. With frame set it instead returns the element of that frame.
+const locateJS = `function(frames, steps, all, frame) {
+	const norm = (s) => (s || '').replace(/\s+/g, ' ').trim().toLowerCase();
+	const visible = (el) => {
+		const style = getComputedStyle(el);
+		return style.visibility !== 'hidden' && style.display !== 'none' && el.getClientRects().length > 0;
+	};
+
+	// deepAll is querySelectorAll that also searches open shadow roots
+	const deepAll = (root, css) => {
+		const found = [...root.querySelectorAll(css)];
+		for (const el of root.querySelectorAll('*')) {
+			if (el.shadowRoot) found.push(...deepAll(el.shadowRoot, css));
+		}
+		return found;
+	};
+
+	const implicitRoles = {
+		button: 'button, input[type=button], input[type=submit], input[type=reset], summary',
+		link: 'a[href], area[href]',
+		textbox: 'input:not([type]), input[type=text], input[type=email], input[type=tel], input[type=url], input[type=search], input[type=password], textarea',
+		checkbox: 'input[type=checkbox]',
+		radio: 'input[type=radio]',
+		combobox: 'select',
+		heading: 'h1, h2, h3, h4, h5, h6',
+		img: 'img[alt]',
+		listitem: 'li',
+		table: 'table',
+		row: 'tr',
+		cell: 'td',
+		form: 'form',
+		navigation: 'nav',
+		dialog: 'dialog',
+	};
+
+	const labelText = (el) => {
+		let text = '';
+		if (el.labels) {
+			for (const l of el.labels) text += ' ' + l.textContent;
+		}
+		return text;
+	};
+
+	const accessibleName = (el) => {
+		if (el.getAttribute('aria-label')) return el.getAttribute('aria-label');
+		const ids = el.getAttribute('aria-labelledby');
+		if (ids) {
+			const root = el.getRootNode();
+			return ids.split(/\s+/).map((id) => {
+				const ref = root.getElementById ? root.getElementById(id) : null;
+				return ref ? ref.textContent : '';
+			}).join(' ');
+		}
+		const label = labelText(el);
+		if (label.trim()) return label;
+		if (el.tagName === 'INPUT' && ['button', 'submit', 'reset'].includes(el.type)) return el.value;
+		if (el.tagName === 'IMG') return el.alt;
+		return el.textContent || el.getAttribute('title') || el.getAttribute('placeholder') || '';
+	};
+
+	// pick returns every candidate, or the first visible one falling back to the first at all
+	const pick = (candidates, many) => {
+		if (many) return candidates;
+		let fallback = null;
+		for (const el of candidates) {
+			if (visible(el)) return el;
+			fallback = fallback || el;
+		}
+		return fallback;
+	};
+
+	const find = (root, step, many) => {
+		const matches = (text, want) => step.exact ? norm(text) === norm(want) : norm(text).includes(norm(want));
+		switch (step.kind) {
+		case 'css':
+			return many ? [...root.querySelectorAll(step.value)] : root.querySelector(step.value);
+		case 'xpath': {
+			const doc = root.ownerDocument || root;
+			if (!many) return doc.evaluate(step.value, root, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue;
+			const snapshot = doc.evaluate(step.value, root, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+			const found = [];
+			for (let i = 0; i < snapshot.snapshotLength; i++) found.push(snapshot.snapshotItem(i));
+			return found;
+		}
+		case 'text': {
+			const found = [];
+			for (const el of deepAll(root.body || root, '*')) {
+				if (['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE'].includes(el.tagName)) continue;
+				if (!matches(el.innerText || el.textContent, step.value)) continue;
+				// Keep the innermost element: drop the match if a child matches too
+				if ([...el.children].some((c) => matches(c.innerText || c.textContent, step.value))) continue;
+				found.push(el);
+			}
+			return pick(found, many);
+		}
+		case 'role': {
+			let selector = '[role=' + JSON.stringify(step.value) + ']';
+			if (implicitRoles[step.value]) selector += ', ' + implicitRoles[step.value];
+			const found = deepAll(root, selector).filter((el) => {
+				const role = el.getAttribute('role');
+				return (!role || role === step.value) && (!step.name || matches(accessibleName(el), step.name));
+			});
+			return pick(found, many);
+		}
+		case 'label': {
+			const found = [];
+			for (const el of deepAll(root, 'input, select, textarea, [contenteditable], [role=textbox], [role=combobox], [role=checkbox]')) {
+				if (matches(labelText(el), step.value) || matches(el.getAttribute('aria-label'), step.value)) found.push(el);
+			}
+			return pick(found, many);
+		}
+		}
+		return many ? [] : null;
+	};
+
+	// locate resolves the steps from root, entering the shadow root of each match but the last
+	const locate = (root, steps, many) => {
+		for (let i = 0; i < steps.length - 1; i++) {
+			const host = find(root, steps[i], false);
+			if (!host || !host.shadowRoot) return many ? [] : null;
+			root = host.shadowRoot;
+		}
+		return find(root, steps[steps.length - 1], many);
+	};
+
+	const frameElement = (root, spec) => {
+		if (spec.by === 'selector') return locate(root, spec.steps, false);
+		const pattern = spec.by === 'url' ? new RegExp(spec.value) : null;
+		for (const el of deepAll(root, 'iframe, frame')) {
+			if (pattern ? pattern.test(el.src) || pattern.test(frameURL(el)) : el.name === spec.value) return el;
+		}
+		return null;
+	};
+
+	const frameURL = (el) => {
+		try {
+			return el.contentWindow.location.href;
+		} catch (e) {
+			return el.src;
+		}
+	};
+
+	let root = document;
+	for (const spec of frames) {
+		const el = frameElement(root, spec);
+		if (!el || !el.contentDocument) return all ? [] : null;
+		root = el.contentDocument;
+	}
+
+	if (frame) return frameElement(root, frame);
+	return locate(root, steps, all);
+}`
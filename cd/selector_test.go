@@ -0,0 +1,76 @@
+package cd
+
+import "testing"
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     strategy
+	}{
+		{"#loginEmail", strategy{kind: "css", value: "#loginEmail"}},
+		{"css=section.buttons > button", strategy{kind: "css", value: "section.buttons > button"}},
+		{"xpath=//button[@id='go']", strategy{kind: "xpath", value: "//button[@id='go']"}},
+		{"//div[@class='amount']", strategy{kind: "xpath", value: "//div[@class='amount']"}},
+		{"(//td)[2]", strategy{kind: "xpath", value: "(//td)[2]"}},
+		{"text=amount due", strategy{kind: "text", value: "amount due"}},
+		{`text="Sign In"`, strategy{kind: "text", value: "Sign In", exact: true}},
+		{"role=button", strategy{kind: "role", value: "button"}},
+		{`role=button[name="Sign In"]`, strategy{kind: "role", value: "button", name: "Sign In", exact: true}},
+		{"role=link[name=Pay]", strategy{kind: "role", value: "link", name: "Pay"}},
+		{"label=Email address", strategy{kind: "label", value: "Email address"}},
+		{`label="Password"`, strategy{kind: "label", value: "Password", exact: true}},
+		{"testid=amount-due", strategy{kind: "css", value: `[data-testid="amount-due"]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			if got := parseSelector(tt.selector); got != tt.want {
+				t.Errorf("parseSelector(%q) = %+v, want %+v", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectorBuilders(t *testing.T) {
+	tests := []struct {
+		got  string
+		want strategy
+	}{
+		{Text(`Pay "now"`), strategy{kind: "text", value: `Pay "now"`, exact: true}},
+		{XPath("//a"), strategy{kind: "xpath", value: "//a"}},
+		{Role("button", ""), strategy{kind: "role", value: "button"}},
+		{Role("button", `Say "hi"`), strategy{kind: "role", value: "button", name: `Say "hi"`, exact: true}},
+		{Label("Email"), strategy{kind: "label", value: "Email"}},
+		{TestID(`odd"id`), strategy{kind: "css", value: `[data-testid="odd\"id"]`}},
+	}
+	for _, tt := range tests {
+		if got := parseSelector(tt.got); got != tt.want {
+			t.Errorf("parseSelector(%q) = %+v, want %+v", tt.got, got, tt.want)
+		}
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []strategy
+	}{
+		{"#amount", []strategy{{kind: "css", value: "#amount"}}},
+		{"payment-form >>> input[name=card]", []strategy{{kind: "css", value: "payment-form"}, {kind: "css", value: "input[name=card]"}}},
+		{`bill-app>>>bill-summary >>> text="Amount due"`, []strategy{{kind: "css", value: "bill-app"}, {kind: "css", value: "bill-summary"}, {kind: "text", value: "Amount due", exact: true}}},
+		{"testid=checkout >>> role=button[name=Pay]", []strategy{{kind: "css", value: `[data-testid="checkout"]`}, {kind: "role", value: "button", name: "Pay"}}},
+		{"div > span", []strategy{{kind: "css", value: "div > span"}}}, // a CSS child combinator is not a shadow step
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got := parseSteps(tt.selector)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseSteps(%q) = %+v, want %+v", tt.selector, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("step %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package cd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// StealthCheck is the outcome of one bot detection check run by StealthSelfTest.
+type StealthCheck struct {
+	Name    string  `json:"name"`
+	Evasion Evasion `json:"evasion"` // the evasion meant to make the check pass
+	Pass    bool    `json:"pass"`
+	Detail  string  `json:"detail"` // the value the check looked at
+}
+
+// StealthSelfTest serves a detection page on the loopback interface, loads it in the browser and reports which checks the browser passes. It shows whether the evasions in use still work against the tricks detectors use.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - timeout is the maximum time in milliseconds to wait for the page to finish its checks.
+//
+// Returns the checks in the order the page ran them, or an error if the page could not be served or did not finish in time.
+func StealthSelfTest(ctx context.Context, timeout int64) ([]StealthCheck, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error serving detection page: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, detectionPage)
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	tctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+
+	var checks []StealthCheck
+	err = chromedp.Run(tctx,
+		chromedp.Navigate("http://"+l.Addr().String()+"/"),
+		chromedp.Poll(`window.stealthChecks`, &checks, chromedp.WithPollingInterval(100*time.Millisecond)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error running detection page: %v", err)
+	}
+	return checks, nil
+}
+
+// detectionPage runs the checks bot detectors commonly use and publishes the results in window.stealthChecks
+const detectionPage = `<!DOCTYPE html>
+<html>
+<head><title>BillBurner stealth self-test</title></head>
+<body>
+<p>Running checks...</p>
+<script>
+(async () => {
+	const checks = [];
+	const check = async (name, evasion, fn) => {
+		try {
+			const [pass, detail] = await fn();
+			checks.push({ name: name, evasion: evasion, pass: !!pass, detail: String(detail) });
+		} catch (e) {
+			checks.push({ name: name, evasion: evasion, pass: false, detail: 'error: ' + e.message });
+		}
+	};
+
+	await check('navigator.webdriver is not true', 'webdriver', () => [navigator.webdriver !== true, navigator.webdriver]);
+
+	await check('plugins are listed', 'plugins', () => [
+		navigator.plugins.length > 0 && navigator.plugins instanceof PluginArray && navigator.mimeTypes.length > 0,
+		navigator.plugins.length + ' plugins, ' + navigator.mimeTypes.length + ' mime types',
+	]);
+
+	await check('languages are set', 'languages', () => [navigator.languages && navigator.languages.length > 0, navigator.languages]);
+
+	await check('window.chrome exists', 'chrome_runtime', () => [
+		!!window.chrome && typeof window.chrome.loadTimes === 'function' && typeof window.chrome.csi === 'function',
+		window.chrome ? Object.keys(window.chrome).join(', ') : 'missing',
+	]);
+
+	await check('notification permission is consistent', 'permissions', async () => {
+		const status = await navigator.permissions.query({ name: 'notifications' });
+		const expected = Notification.permission === 'default' ? 'prompt' : Notification.permission;
+		return [status.state === expected, 'query ' + status.state + ', Notification ' + Notification.permission];
+	});
+
+	await check('WebGL does not report SwiftShader', 'webgl', () => {
+		const gl = document.createElement('canvas').getContext('webgl');
+		if (!gl) return [false, 'no WebGL context'];
+		const info = gl.getExtension('WEBGL_debug_renderer_info');
+		const vendor = info ? gl.getParameter(info.UNMASKED_VENDOR_WEBGL) : gl.getParameter(gl.VENDOR);
+		const renderer = info ? gl.getParameter(info.UNMASKED_RENDERER_WEBGL) : gl.getParameter(gl.RENDERER);
+		return [!/swiftshader|llvmpipe|google/i.test(vendor + ' ' + renderer), vendor + ' / ' + renderer];
+	});
+
+	await check('hardwareConcurrency is plausible', 'hardware_concurrency', () => [navigator.hardwareConcurrency >= 2, navigator.hardwareConcurrency]);
+
+	await check('userAgentData has no HeadlessChrome brand', 'user_agent_data', () => {
+		if (!navigator.userAgentData) return [true, 'not exposed'];
+		const brands = navigator.userAgentData.brands.map((b) => b.brand);
+		return [!brands.includes('HeadlessChrome'), brands.join(', ')];
+	});
+
+	await check('user agent is not headless', 'headless_user_agent', () => [!/HeadlessChrome/.test(navigator.userAgent), navigator.userAgent]);
+
+	await check('iframe windows have window.chrome', 'iframe_content_window', () => {
+		const iframe = document.createElement('iframe');
+		iframe.srcdoc = '<p>frame</p>';
+		document.body.appendChild(iframe);
+		const ok = !!iframe.contentWindow && !!iframe.contentWindow.chrome;
+		iframe.remove();
+		return [ok, ok ? 'present' : 'missing'];
+	});
+
+	await check('H.264 video is playable', 'media_codecs', () => {
+		const result = document.createElement('video').canPlayType('video/mp4; codecs="avc1.42E01E"');
+		return [result !== '', result || 'empty'];
+	});
+
+	await check('patched functions look native', '', () => {
+		const fns = [
+			HTMLMediaElement.prototype.canPlayType,
+			WebGLRenderingContext.prototype.getParameter,
+			Permissions.prototype.query,
+			Function.prototype.toString,
+		];
+		const bad = fns.filter((fn) => !/\[native code\]/.test(Function.prototype.toString.call(fn)));
+		return [bad.length === 0, bad.length === 0 ? 'all native' : bad.map((fn) => fn.name).join(', ')];
+	});
+
+	window.stealthChecks = checks;
+	document.querySelector('p').textContent = 'Done';
+})();
+</script>
+</body>
+</html>`
@@ -0,0 +1,345 @@
+package cd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// Evasion is one of the patches applied to every page when BrowserOptions.Bypass is set. Each hides one trait that tells an automated or headless Chrome apart from a person's.
+type Evasion string
+
+const (
+	// EvasionWebdriver reports navigator.webdriver as false.
+	EvasionWebdriver Evasion = "webdriver"
+
+	// EvasionPlugins gives navigator.plugins and navigator.mimeTypes the PDF viewers a desktop Chrome lists.
+	EvasionPlugins Evasion = "plugins"
+
+	// EvasionLanguages makes sure navigator.languages is not empty.
+	EvasionLanguages Evasion = "languages"
+
+	// EvasionChromeRuntime adds the window.chrome object headless Chrome lacks.
+	EvasionChromeRuntime Evasion = "chrome_runtime"
+
+	// EvasionPermissions makes the notifications permission query agree with Notification.permission.
+	EvasionPermissions Evasion = "permissions"
+
+	// EvasionWebGL reports a common GPU as the WebGL vendor and renderer instead of SwiftShader.
+	EvasionWebGL Evasion = "webgl"
+
+	// EvasionHardwareConcurrency reports 4 CPU cores when the machine, often a container, has fewer.
+	EvasionHardwareConcurrency Evasion = "hardware_concurrency"
+
+	// EvasionUserAgentData replaces the HeadlessChrome brand in navigator.userAgentData with Google Chrome.
+	EvasionUserAgentData Evasion = "user_agent_data"
+
+	// EvasionHeadlessUserAgent removes HeadlessChrome from the user agent string, in request headers as well as navigator.userAgent. It has no effect when BrowserOptions.UserAgent is set.
+	EvasionHeadlessUserAgent Evasion = "headless_user_agent"
+
+	// EvasionIframeContentWindow gives the windows of srcdoc and about:blank iframes the window.chrome object too.
+	EvasionIframeContentWindow Evasion = "iframe_content_window"
+
+	// EvasionMediaCodecs reports the H.264 and AAC codecs Chrome supports but Chromium builds do not.
+	EvasionMediaCodecs Evasion = "media_codecs"
+)
+
+// Evasions lists every evasion in the order they are applied.
+var Evasions = []Evasion{
+	EvasionWebdriver,
+	EvasionPlugins,
+	EvasionLanguages,
+	EvasionChromeRuntime,
+	EvasionPermissions,
+	EvasionWebGL,
+	EvasionHardwareConcurrency,
+	EvasionUserAgentData,
+	EvasionHeadlessUserAgent,
+	EvasionIframeContentWindow,
+	EvasionMediaCodecs,
+}
+
+// ParseEvasions reads a comma separated list of evasion names, e.g. "webgl,media_codecs".
+func ParseEvasions(list string) ([]Evasion, error) {
+	var evasions []Evasion
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := evasionScripts[Evasion(name)]; !ok {
+			return nil, fmt.Errorf("unknown evasion %q", name)
+		}
+		evasions = append(evasions, Evasion(name))
+	}
+	return evasions, nil
+}
+
+// StealthScript returns the script that applies the evasions, all of them when none are given. It is meant to run before any page script, see page.AddScriptToEvaluateOnNewDocument.
+func StealthScript(evasions ...Evasion) string {
+	if len(evasions) == 0 {
+		evasions = Evasions
+	}
+	var sb strings.Builder
+	sb.WriteString("(() => {\n")
+	sb.WriteString(stealthPrelude)
+	for _, e := range evasions {
+		script, ok := evasionScripts[e]
+		if !ok {
+			continue
+		}
+		// One failing evasion must not stop the rest
+		fmt.Fprintf(&sb, "try {\n%s\n} catch (e) {}\n", script)
+	}
+	sb.WriteString("})();")
+	return sb.String()
+}
+
+// applyStealth installs the stealth script for every new document and, unless a user agent was given, strips HeadlessChrome from the one the browser sends.
+func applyStealth(ctx context.Context, opts BrowserOptions) error {
+	evasions := opts.Evasions
+	if len(evasions) == 0 {
+		evasions = Evasions
+	}
+
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(StealthScript(evasions...)).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return fmt.Errorf("error adding stealth script: %v", err)
+	}
+
+	if opts.UserAgent != "" || !hasEvasion(evasions, EvasionHeadlessUserAgent) {
+		return nil
+	}
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, _, _, ua, _, err := browser.GetVersion().Do(ctx)
+		if err != nil {
+			return fmt.Errorf("error reading user agent: %v", err)
+		}
+		if !strings.Contains(ua, "HeadlessChrome") {
+			return nil
+		}
+		if err := emulation.SetUserAgentOverride(strings.ReplaceAll(ua, "HeadlessChrome", "Chrome")).Do(ctx); err != nil {
+			return fmt.Errorf("error setting user agent: %v", err)
+		}
+		return nil
+	}))
+}
+
+func hasEvasion(evasions []Evasion, e Evasion) bool {
+	for _, x := range evasions {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+// stealthPrelude holds helpers shared by the evasion scripts. Patched functions and getters are made to look native, since detectors check Function.prototype.toString.
+const stealthPrelude = `
+const nativeNames = new WeakMap();
+const originalToString = Function.prototype.toString;
+const patchedToString = function toString() {
+	if (nativeNames.has(this)) return 'function ' + nativeNames.get(this) + '() { [native code] }';
+	return originalToString.call(this);
+};
+nativeNames.set(patchedToString, 'toString');
+Function.prototype.toString = patchedToString;
+
+const native = (fn, name) => {
+	nativeNames.set(fn, name || fn.name);
+	return fn;
+};
+
+// getter replaces a property on a prototype with a native-looking getter
+const getter = (proto, prop, get) => {
+	const desc = Object.getOwnPropertyDescriptor(proto, prop) || { configurable: true, enumerable: true };
+	Object.defineProperty(proto, prop, { ...desc, get: native(get, 'get ' + prop) });
+};
+`
+
+var evasionScripts = map[Evasion]string{
+	EvasionWebdriver: `
+getter(Navigator.prototype, 'webdriver', () => false);`,
+
+	EvasionPlugins: `
+if (navigator.plugins.length === 0) {
+	const mimeTypes = [
+		{ type: 'application/pdf', suffixes: 'pdf', description: 'Portable Document Format' },
+		{ type: 'text/pdf', suffixes: 'pdf', description: 'Portable Document Format' },
+	];
+	const names = ['PDF Viewer', 'Chrome PDF Viewer', 'Chromium PDF Viewer', 'Microsoft Edge PDF Viewer', 'WebKit built-in PDF'];
+
+	const makeArray = (items, proto, key) => {
+		const arr = Object.create(proto);
+		items.forEach((item, i) => {
+			arr[i] = item;
+			arr[item[key]] = item;
+		});
+		Object.defineProperty(arr, 'length', { value: items.length });
+		arr.item = native(function item(i) { return items[i] || null; });
+		arr.namedItem = native(function namedItem(name) { return items.find((x) => x[key] === name) || null; });
+		arr[Symbol.iterator] = native(function values() { return items[Symbol.iterator](); }, 'values');
+		return arr;
+	};
+
+	const plugins = names.map((name) => {
+		const plugin = Object.create(Plugin.prototype);
+		Object.defineProperties(plugin, {
+			name: { value: name },
+			filename: { value: 'internal-pdf-viewer' },
+			description: { value: 'Portable Document Format' },
+			length: { value: mimeTypes.length },
+		});
+		return plugin;
+	});
+	const mimes = mimeTypes.map((m) => {
+		const mime = Object.create(MimeType.prototype);
+		Object.defineProperties(mime, {
+			type: { value: m.type },
+			suffixes: { value: m.suffixes },
+			description: { value: m.description },
+			enabledPlugin: { value: plugins[0] },
+		});
+		return mime;
+	});
+	plugins.forEach((plugin) => mimes.forEach((mime, i) => { plugin[i] = mime; plugin[mime.type] = mime; }));
+
+	const pluginArray = makeArray(plugins, PluginArray.prototype, 'name');
+	pluginArray.refresh = native(function refresh() {});
+	const mimeTypeArray = makeArray(mimes, MimeTypeArray.prototype, 'type');
+	getter(Navigator.prototype, 'plugins', () => pluginArray);
+	getter(Navigator.prototype, 'mimeTypes', () => mimeTypeArray);
+	getter(Navigator.prototype, 'pdfViewerEnabled', () => true);
+}`,
+
+	EvasionLanguages: `
+if (!navigator.languages || navigator.languages.length === 0) {
+	const languages = Object.freeze(['en-US', 'en']);
+	getter(Navigator.prototype, 'languages', () => languages);
+}`,
+
+	EvasionChromeRuntime: `
+if (!window.chrome) {
+	Object.defineProperty(window, 'chrome', { value: {}, writable: true, enumerable: true, configurable: false });
+}
+if (!window.chrome.runtime) {
+	window.chrome.runtime = {
+		OnInstalledReason: { CHROME_UPDATE: 'chrome_update', INSTALL: 'install', SHARED_MODULE_UPDATE: 'shared_module_update', UPDATE: 'update' },
+		PlatformOs: { ANDROID: 'android', CROS: 'cros', LINUX: 'linux', MAC: 'mac', OPENBSD: 'openbsd', WIN: 'win' },
+		connect: native(function connect() { throw new TypeError('Error in invocation of runtime.connect'); }),
+		sendMessage: native(function sendMessage() { throw new TypeError('Error in invocation of runtime.sendMessage'); }),
+	};
+}
+if (!window.chrome.app) {
+	window.chrome.app = {
+		isInstalled: false,
+		InstallState: { DISABLED: 'disabled', INSTALLED: 'installed', NOT_INSTALLED: 'not_installed' },
+		RunningState: { CANNOT_RUN: 'cannot_run', READY_TO_RUN: 'ready_to_run', RUNNING: 'running' },
+		getDetails: native(function getDetails() { return null; }),
+		getIsInstalled: native(function getIsInstalled() { return false; }),
+	};
+}
+if (!window.chrome.csi) {
+	window.chrome.csi = native(function csi() {
+		const t = performance.timing;
+		return { onloadT: t.domContentLoadedEventEnd, startE: t.navigationStart, pageT: performance.now(), tran: 15 };
+	});
+}
+if (!window.chrome.loadTimes) {
+	window.chrome.loadTimes = native(function loadTimes() {
+		const t = performance.timing;
+		return {
+			requestTime: t.navigationStart / 1000, startLoadTime: t.navigationStart / 1000,
+			commitLoadTime: t.responseStart / 1000, finishDocumentLoadTime: t.domContentLoadedEventEnd / 1000,
+			finishLoadTime: t.loadEventEnd / 1000, firstPaintTime: t.loadEventEnd / 1000, firstPaintAfterLoadTime: 0,
+			navigationType: 'Other', wasFetchedViaSpdy: true, wasNpnNegotiated: true, npnNegotiatedProtocol: 'h2',
+			wasAlternateProtocolAvailable: false, connectionInfo: 'h2',
+		};
+	});
+}`,
+
+	EvasionPermissions: `
+if (window.Permissions && Permissions.prototype.query) {
+	const originalQuery = Permissions.prototype.query;
+	Permissions.prototype.query = native(function query(parameters) {
+		if (parameters && parameters.name === 'notifications') {
+			const state = Notification.permission === 'default' ? 'prompt' : Notification.permission;
+			return Promise.resolve(Object.setPrototypeOf({ state: state, onchange: null }, PermissionStatus.prototype));
+		}
+		return originalQuery.call(this, parameters);
+	});
+}`,
+
+	EvasionWebGL: `
+for (const ctx of [window.WebGLRenderingContext, window.WebGL2RenderingContext]) {
+	if (!ctx) continue;
+	const originalGetParameter = ctx.prototype.getParameter;
+	ctx.prototype.getParameter = native(function getParameter(p) {
+		if (p === 37445) return 'Intel Inc.'; // UNMASKED_VENDOR_WEBGL
+		if (p === 37446) return 'Intel Iris OpenGL Engine'; // UNMASKED_RENDERER_WEBGL
+		return originalGetParameter.call(this, p);
+	});
+}`,
+
+	EvasionHardwareConcurrency: `
+if (navigator.hardwareConcurrency < 4) {
+	getter(Navigator.prototype, 'hardwareConcurrency', () => 4);
+}`,
+
+	EvasionUserAgentData: `
+if (navigator.userAgentData) {
+	const uaData = navigator.userAgentData;
+	const fix = (brands) => brands.map((b) => b.brand === 'HeadlessChrome' ? { brand: 'Google Chrome', version: b.version } : b);
+	const brands = Object.freeze(fix(uaData.brands));
+	const originalGetHighEntropyValues = uaData.getHighEntropyValues;
+	getter(Object.getPrototypeOf(uaData), 'brands', () => brands);
+	Object.getPrototypeOf(uaData).getHighEntropyValues = native(function getHighEntropyValues(hints) {
+		return originalGetHighEntropyValues.call(this, hints).then((values) => {
+			if (values.brands) values.brands = fix(values.brands);
+			if (values.fullVersionList) values.fullVersionList = fix(values.fullVersionList);
+			return values;
+		});
+	});
+}`,
+
+	EvasionHeadlessUserAgent: `
+if (navigator.userAgent.includes('HeadlessChrome')) {
+	const userAgent = navigator.userAgent.replace('HeadlessChrome', 'Chrome');
+	const appVersion = navigator.appVersion.replace('HeadlessChrome', 'Chrome');
+	getter(Navigator.prototype, 'userAgent', () => userAgent);
+	getter(Navigator.prototype, 'appVersion', () => appVersion);
+}`,
+
+	EvasionIframeContentWindow: `
+const originalContentWindow = Object.getOwnPropertyDescriptor(HTMLIFrameElement.prototype, 'contentWindow');
+getter(HTMLIFrameElement.prototype, 'contentWindow', function () {
+	const w = originalContentWindow.get.call(this);
+	try {
+		// Same-origin frames that never navigated skip the stealth script, so give them the parent's chrome object
+		if (w && !w.chrome && window.chrome) {
+			Object.defineProperty(w, 'chrome', { value: window.chrome, writable: true, enumerable: true, configurable: false });
+		}
+	} catch (e) {}
+	return w;
+});`,
+
+	EvasionMediaCodecs: `
+const originalCanPlayType = HTMLMediaElement.prototype.canPlayType;
+HTMLMediaElement.prototype.canPlayType = native(function canPlayType(type) {
+	const result = originalCanPlayType.call(this, type);
+	if (result !== '' || typeof type !== 'string') return result;
+	const t = type.toLowerCase().replace(/\s/g, '');
+	if (t.startsWith('video/mp4') && t.includes('avc1')) return 'probably';
+	if (t === 'audio/x-m4a' || t === 'audio/aac' || t === 'video/mp4') return 'maybe';
+	if (t.startsWith('audio/mp4') && t.includes('mp4a')) return 'probably';
+	return result;
+});`,
+}
@@ -0,0 +1,267 @@
+package cd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// pollInterval is how often the waits re-check their condition
+const pollInterval = 100 * time.Millisecond
+
+// TimeoutError is returned by the Wait functions when their condition is not met in time. It matches context.DeadlineExceeded with errors.Is.
+type TimeoutError struct {
+	What    string // the condition waited for, e.g. `selector ".amount" to be visible`
+	Timeout time.Duration
+	Last    error // the last error seen while checking, if any
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("timed out after %d ms waiting for %s", e.Timeout.Milliseconds(), e.What)
+	if e.Last != nil {
+		msg += ": " + e.Last.Error()
+	}
+	return msg
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// waitUntil re-checks the condition until it holds, ctx is done or the timeout passes. Errors from check count as the condition not holding yet, since the element or page it looks at may still be loading.
+func waitUntil(ctx context.Context, timeout int64, what string, check func(ctx context.Context) (bool, error)) error {
+	return waitEvery(ctx, timeout, pollInterval, what, check)
+}
+
+// waitEvery is waitUntil for checks too costly to run every pollInterval
+func waitEvery(ctx context.Context, timeout int64, interval time.Duration, what string, check func(ctx context.Context) (bool, error)) error {
+	limit := time.Duration(timeout) * time.Millisecond
+	deadline := time.Now().Add(limit)
+	var last error
+	for {
+		ok, err := check(ctx)
+		if err == nil && ok {
+			return nil
+		}
+		last = err
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return &TimeoutError{What: what, Timeout: limit, Last: last}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WaitVisible waits until an element is present and rendered: not hidden by display or visibility and taking up space on the page.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - selector is the selector of the element to wait for.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// Returns a *TimeoutError if the element is not visible in time, or ctx's error if it is cancelled first.
+func WaitVisible(ctx context.Context, selector string, timeout int64) error {
+	return waitUntil(ctx, timeout, fmt.Sprintf("selector %q to be visible", selector), func(ctx context.Context) (bool, error) {
+		var visible bool
+		err := CallOn(ctx, resolve(ctx, selector, "WaitVisible"), `function() {
+			const style = getComputedStyle(this);
+			const rect = this.getBoundingClientRect();
+			return style.display !== 'none' && style.visibility !== 'hidden' && rect.width > 0 && rect.height > 0;
+		}`, &visible)
+		return visible, err
+	})
+}
+
+// WaitText waits until an element shows some text and returns it, trimmed. Use it instead of a fixed sleep when a value is filled in after the element appears.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - selector is the selector of the element to read.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// Returns a *TimeoutError if the element stays empty, or ctx's error if it is cancelled first.
+func WaitText(ctx context.Context, selector string, timeout int64) (string, error) {
+	var text string
+	err := waitUntil(ctx, timeout, fmt.Sprintf("selector %q to have text", selector), func(ctx context.Context) (bool, error) {
+		var err error
+		text, err = elementText(ctx, selector, "WaitText")
+		return text != "", err
+	})
+	return text, err
+}
+
+// WaitTextMatch waits until the text of an element matches a regular expression and returns the text, trimmed.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - selector is the selector of the element to read.
+//
+// - pattern is the expression the text must match, e.g. `\$\d` for a dollar amount.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// Returns a *TimeoutError if the text does not match in time, or ctx's error if it is cancelled first.
+func WaitTextMatch(ctx context.Context, selector string, pattern *regexp.Regexp, timeout int64) (string, error) {
+	var text string
+	err := waitUntil(ctx, timeout, fmt.Sprintf("selector %q text to match %q", selector, pattern), func(ctx context.Context) (bool, error) {
+		var err error
+		text, err = elementText(ctx, selector, "WaitTextMatch")
+		return pattern.MatchString(text), err
+	})
+	return text, err
+}
+
+// WaitTextChange waits until the text of an element is no longer old nor empty and returns it, trimmed. Use it when a click replaces a value in place, such as switching tabs, so the value from before the click is not read.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - selector is the selector of the element to read.
+//
+// - old is the text the element showed before, as returned by the other Wait functions.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// Returns a *TimeoutError if the text does not change in time, or ctx's error if it is cancelled first.
+func WaitTextChange(ctx context.Context, selector, old string, timeout int64) (string, error) {
+	var text string
+	err := waitUntil(ctx, timeout, fmt.Sprintf("selector %q text to change from %q", selector, old), func(ctx context.Context) (bool, error) {
+		var err error
+		text, err = elementText(ctx, selector, "WaitTextChange")
+		return text != "" && text != old, err
+	})
+	return text, err
+}
+
+// elementText reads the rendered text of the first element matching the selector
+func elementText(ctx context.Context, selector, step string) (string, error) {
+	var text string
+	err := CallOn(ctx, resolve(ctx, selector, step), `function() { return (this.innerText || this.textContent || '').trim(); }`, &text)
+	return text, err
+}
+
+// WaitDetached waits until no element matches a selector, such as a loading spinner going away.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - selector is the selector of the element that should disappear.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// Returns a *TimeoutError if the element is still there, or ctx's error if it is cancelled first.
+func WaitDetached(ctx context.Context, selector string, timeout int64) error {
+	return waitUntil(ctx, timeout, fmt.Sprintf("selector %q to be removed", selector), func(ctx context.Context) (bool, error) {
+		el, err := element(ctx, selector)
+		if err != nil {
+			return false, err
+		}
+		release(ctx, el)
+		return el.ObjectID == "", nil
+	})
+}
+
+// WaitURL waits until the address of the page matches a regular expression and returns it, e.g. after a login redirects to the dashboard.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - pattern is the expression the address must match.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// Returns a *TimeoutError if the address does not match in time, or ctx's error if it is cancelled first.
+func WaitURL(ctx context.Context, pattern *regexp.Regexp, timeout int64) (string, error) {
+	var url string
+	err := waitUntil(ctx, timeout, fmt.Sprintf("URL to match %q", pattern), func(ctx context.Context) (bool, error) {
+		err := chromedp.Run(ctx, chromedp.Location(&url))
+		return pattern.MatchString(url), err
+	})
+	return url, err
+}
+
+// WaitFunc waits until a JavaScript function returns a truthy value. The function and arguments are passed as in Call.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - fn is the predicate, e.g. `function(n) { return document.querySelectorAll('tr').length >= n; }`.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// - args are the arguments passed to fn.
+//
+// Returns a *TimeoutError if the predicate stays falsy, or ctx's error if it is cancelled first.
+func WaitFunc(ctx context.Context, fn string, timeout int64, args ...interface{}) error {
+	return waitUntil(ctx, timeout, "predicate to hold", func(ctx context.Context) (bool, error) {
+		var res interface{}
+		err := Call(ctx, fn, &res, args...)
+		return truthy(res), err
+	})
+}
+
+// truthy applies JavaScript's truthiness to a decoded JSON value
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	default:
+		return true
+	}
+}
+
+// WaitNetworkIdle waits until the page has had no requests in flight for a stretch of time, so content loaded by scripts after the page itself has arrived.
+//
+// - ctx is the Chromedp context which manages the underlying browser actions and states.
+//
+// - idle is how long in milliseconds the network must stay quiet, 500 is usually enough.
+//
+// - timeout is the maximum time in milliseconds to wait.
+//
+// Only requests started after the call are tracked. Returns a *TimeoutError if the network never settles, or ctx's error if it is cancelled first.
+func WaitNetworkIdle(ctx context.Context, idle int64, timeout int64) error {
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	inflight := map[network.RequestID]bool{}
+	lastActivity := time.Now()
+	chromedp.ListenTarget(lctx, func(ev interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			inflight[ev.RequestID] = true
+		case *network.EventLoadingFinished:
+			delete(inflight, ev.RequestID)
+		case *network.EventLoadingFailed:
+			delete(inflight, ev.RequestID)
+		default:
+			return
+		}
+		lastActivity = time.Now()
+	})
+
+	quiet := time.Duration(idle) * time.Millisecond
+	return waitUntil(ctx, timeout, fmt.Sprintf("network to be idle for %d ms", idle), func(ctx context.Context) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(inflight) == 0 && time.Since(lastActivity) >= quiet, nil
+	})
+}
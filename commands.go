@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runCommand handles the subcommands that work from stored data instead of scraping the provider sites
+func runCommand(args []string) error {
+	switch args[0] {
+	case "forecast":
+		days := 90
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid number of days %q", args[1])
+			}
+			days = n
+		}
+
+		st, err := loadStore(storePath())
+		if err != nil {
+			return err
+		}
+		renderForecast(buildForecast(st.latestBills(), st, time.Now(), days))
+		return nil
+	case "loan":
+		if len(args) > 1 {
+			l, ok := cfg.loan(args[1])
+			if !ok {
+				return fmt.Errorf("no loan named %q in config", args[1])
+			}
+			renderLoanSchedule(l)
+		}
+
+		st, err := loadStore(storePath())
+		if err != nil {
+			return err
+		}
+		renderLoanSummaries(loanSummaries(st.latestBills(), time.Now()))
+		return nil
+	case "split":
+		return splitCommand(args[1:])
+	case "bank":
+		return bankCommand(args[1:])
+	case "journal":
+		if len(cfg.Journals) == 0 {
+			return fmt.Errorf("no journals configured")
+		}
+		st, err := loadStore(storePath())
+		if err != nil {
+			return err
+		}
+		exportJournals(st)
+		return nil
+	case "budget":
+		if cfg.Budget == nil {
+			return fmt.Errorf("no budget exports configured")
+		}
+		st, err := loadStore(storePath())
+		if err != nil {
+			return err
+		}
+		if err := writeBudgetExports(cfg.Budget, st.latestBills()); err != nil {
+			return err
+		}
+		fmt.Println("Wrote budget exports to", cfg.Budget.Dir)
+		return nil
+	case "mqtt":
+		return mqttCommand(args[1:])
+	case "statements":
+		return statementCommand(args[1:])
+	case "stealth":
+		return stealthCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// splitCommand shows the housemate ledger, or records a payment with "split pay <person> <amount> [memo]" or a repayment with "split transfer <from> <to> <amount>"
+func splitCommand(args []string) error {
+	if cfg.Splits == nil {
+		return fmt.Errorf("no splits configured")
+	}
+
+	st, err := loadStore(storePath())
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		renderSplits(cfg.Splits, st)
+		return nil
+	}
+
+	known := func(person string) error {
+		for _, p := range cfg.Splits.People {
+			if p == person {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown person %q", person)
+	}
+
+	payment := splitPayment{Date: time.Now().Unix()}
+	switch args[0] {
+	case "pay":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: split pay <person> <amount> [memo]")
+		}
+		payment.Person = args[1]
+		payment.Amount, err = strconv.ParseFloat(args[2], 64)
+		payment.Memo = strings.Join(args[3:], " ")
+	case "transfer":
+		if len(args) != 4 {
+			return fmt.Errorf("usage: split transfer <from> <to> <amount>")
+		}
+		payment.Person, payment.To = args[1], args[2]
+		if err := known(payment.To); err != nil {
+			return err
+		}
+		payment.Amount, err = strconv.ParseFloat(args[3], 64)
+	default:
+		return fmt.Errorf("unknown split command %q", args[0])
+	}
+	if err != nil || payment.Amount <= 0 {
+		return fmt.Errorf("invalid amount")
+	}
+	if err := known(payment.Person); err != nil {
+		return err
+	}
+
+	st.Payments = append(st.Payments, payment)
+	if err := st.save(); err != nil {
+		return err
+	}
+	renderSplits(cfg.Splits, st)
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var cfg *config
+
+// config is the optional JSON configuration file. Secrets stay in .env; this file describes the bills themselves.
+type config struct {
+	// Provider logins to scrape. When empty, one account per provider is used with the default credentials.
+	Accounts []account `json:"accounts"`
+
+	// Recurring bills with a fixed amount on a predictable schedule, such as loan payments
+	Recurring []recurringBill `json:"recurring"`
+
+	// Amortizing loans, matched to bills by name
+	Loans []loan `json:"loans"`
+
+	// How shared bills are divided between housemates
+	Splits *splitConfig `json:"splits"`
+
+	// Bank exports used to confirm bills were paid
+	Bank *bankConfig `json:"bank"`
+
+	// Plain-text accounting journals every billing cycle is appended to
+	Journals []journalConfig `json:"journals"`
+
+	// CSV exports of the upcoming bills for budgeting apps
+	Budget *budgetConfig `json:"budget"`
+
+	// Extra non-business days (YYYY-MM-DD) on top of the US federal holidays
+	Holidays []string `json:"holidays"`
+
+	holidays map[string]bool
+}
+
+func configPath() string {
+	if path := os.Getenv("BILLBURNER_CONFIG"); path != "" {
+		return path
+	}
+	return "config.json"
+}
+
+// loadConfig reads and validates the config file. A missing file yields an empty config.
+func loadConfig(path string) (*config, error) {
+	c := &config{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading config %s: %v", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("error parsing config %s: %v", path, err)
+		}
+	}
+
+	c.holidays = map[string]bool{}
+	for _, day := range c.Holidays {
+		t, err := parseConfigDate(day)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q: %v", day, err)
+		}
+		c.holidays[t.Format(dateLayout)] = true
+	}
+
+	if len(c.Accounts) == 0 {
+		c.Accounts = append(c.Accounts, defaultAccounts...)
+	}
+	for i := range c.Accounts {
+		if err := c.Accounts[i].validate(); err != nil {
+			return nil, fmt.Errorf("account %d: %v", i+1, err)
+		}
+	}
+
+	// Bills are stored, split, journaled and published by type and nickname, so two accounts producing the same pair would overwrite each other
+	owners := map[billKey]string{}
+	for i, a := range c.Accounts {
+		for _, billType := range providers[a.Provider].bills {
+			key := billKey{billType, a.Nickname}
+			if owner, ok := owners[key]; ok {
+				return nil, fmt.Errorf("account %d: %s bill is also produced by %s, give one of them a nickname", i+1, key.label(), owner)
+			}
+			owners[key] = fmt.Sprintf("account %d", i+1)
+		}
+	}
+
+	for i := range c.Recurring {
+		rb := &c.Recurring[i]
+		key := billKey{rb.Name, ""}
+		if owner, ok := owners[key]; ok {
+			return nil, fmt.Errorf("recurring bill %q: name is also used by %s", rb.Name, owner)
+		}
+		owners[key] = fmt.Sprintf("recurring bill %q", rb.Name)
+		if err := rb.validate(); err != nil {
+			return nil, fmt.Errorf("recurring bill %q: %v", rb.Name, err)
+		}
+	}
+
+	for i := range c.Loans {
+		if err := c.Loans[i].validate(); err != nil {
+			return nil, fmt.Errorf("loan %q: %v", c.Loans[i].Name, err)
+		}
+	}
+
+	if c.Splits != nil {
+		if err := c.Splits.validate(); err != nil {
+			return nil, fmt.Errorf("splits: %v", err)
+		}
+	}
+
+	if c.Bank != nil {
+		if err := c.Bank.validate(); err != nil {
+			return nil, fmt.Errorf("bank: %v", err)
+		}
+	}
+
+	for i := range c.Journals {
+		if err := c.Journals[i].validate(); err != nil {
+			return nil, fmt.Errorf("journal %d: %v", i+1, err)
+		}
+	}
+
+	if c.Budget != nil {
+		if err := c.Budget.validate(); err != nil {
+			return nil, fmt.Errorf("budget: %v", err)
+		}
+	}
+
+	return c, nil
+}
+
+// recurringBill looks up a recurring bill by name
+func (c *config) recurringBill(name string) (recurringBill, bool) {
+	for _, rb := range c.Recurring {
+		if rb.Name == name {
+			return rb, true
+		}
+	}
+	return recurringBill{}, false
+}
+
+// loan looks up a loan by name
+func (c *config) loan(name string) (loan, bool) {
+	for _, l := range c.Loans {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return loan{}, false
+}
+
+const dateLayout = "2006-01-02"
+
+func parseConfigDate(s string) (time.Time, error) {
+	return time.ParseInLocation(dateLayout, s, time.Local)
+}
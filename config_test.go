@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("REPORT_DIR", "out")
+
+	tests := []struct {
+		name    string
+		json    string // "" leaves the config file out
+		wantErr string
+		check   func(t *testing.T, c *config)
+	}{
+		{
+			name: "no config file",
+			check: func(t *testing.T, c *config) {
+				if len(c.Accounts) != len(defaultAccounts) {
+					t.Fatalf("got %d accounts, want one per provider", len(c.Accounts))
+				}
+				for _, a := range c.Accounts {
+					if a.Credentials != providers[a.Provider].credentials {
+						t.Errorf("%s credentials = %q, want %q", a.Provider, a.Credentials, providers[a.Provider].credentials)
+					}
+				}
+			},
+		},
+		{
+			name: "defaults filled in",
+			json: `{
+				"accounts": [{"provider": "ameren"}, {"provider": "ameren", "nickname": "Rental", "credentials": "AMEREN_RENTAL"}],
+				"recurring": [
+					{"name": "Lawn", "amount": 40, "rule": "weekly", "start": "2026-04-03"},
+					{"name": "Water Softener", "amount": 25, "rule": "quarterly", "day": 5}
+				],
+				"bank": {"csv": {"date": "Date", "payee": "Payee", "amount": "Amount"}, "payees": [{"bill": "Power", "pattern": "AMEREN"}]},
+				"journals": [{"format": "ledger", "path": "bills.ledger"}],
+				"budget": {"formats": ["ynab"]},
+				"holidays": ["2026-11-27"]
+			}`,
+			check: func(t *testing.T, c *config) {
+				if c.Accounts[0].Credentials != "AMEREN" || c.Accounts[1].Credentials != "AMEREN_RENTAL" {
+					t.Errorf("credentials = %q and %q", c.Accounts[0].Credentials, c.Accounts[1].Credentials)
+				}
+				if c.Recurring[0].Interval != 1 || c.Recurring[1].Month != 1 {
+					t.Errorf("weekly interval = %d, quarterly month = %d, want 1 and 1", c.Recurring[0].Interval, c.Recurring[1].Month)
+				}
+				if c.Bank.CSV.DateFormat != "01/02/2006" || c.Bank.Payees[0].Tolerance != 1 || c.Bank.Payees[0].Days != 10 {
+					t.Errorf("bank defaults = %+v, %+v", *c.Bank.CSV, c.Bank.Payees[0])
+				}
+				if c.Journals[0].Currency != "USD" {
+					t.Errorf("journal currency = %q, want USD", c.Journals[0].Currency)
+				}
+				if want := filepath.Join("out", "budget"); c.Budget.Dir != want {
+					t.Errorf("budget dir = %q, want %q", c.Budget.Dir, want)
+				}
+				if !c.holidays["2026-11-27"] {
+					t.Errorf("holidays = %v", c.holidays)
+				}
+			},
+		},
+		{
+			name:    "same provider twice without a nickname",
+			json:    `{"accounts": [{"provider": "att"}, {"provider": "att", "credentials": "ATT_RENTAL"}]}`,
+			wantErr: "account 2: Internet bill is also produced by account 1",
+		},
+		{
+			name:    "recurring bill named like a scraped one",
+			json:    `{"accounts": [{"provider": "ameren"}], "recurring": [{"name": "Power", "amount": 100, "rule": "monthly", "day": 1}]}`,
+			wantErr: `recurring bill "Power": name is also used by account 1`,
+		},
+		{
+			name: "recurring bill named twice",
+			json: `{"accounts": [{"provider": "ameren"}], "recurring": [
+				{"name": "Car", "amount": 422.94, "rule": "monthly", "day": 17},
+				{"name": "Car", "amount": 300, "rule": "monthly", "day": 2}
+			]}`,
+			wantErr: `recurring bill "Car": name is also used by recurring bill "Car"`,
+		},
+		{
+			name:    "unknown provider",
+			json:    `{"accounts": [{"provider": "comcast"}]}`,
+			wantErr: `account 1: unknown provider "comcast"`,
+		},
+		{
+			name:    "invalid holiday",
+			json:    `{"holidays": ["11/27/2026"]}`,
+			wantErr: `invalid holiday "11/27/2026"`,
+		},
+		{
+			name:    "invalid JSON",
+			json:    `{"accounts": [}`,
+			wantErr: "error parsing config",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if tt.json != "" {
+				if err := os.WriteFile(path, []byte(tt.json), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c, err := loadConfig(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, c)
+		})
+	}
+}
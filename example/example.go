@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
@@ -57,3 +58,32 @@ func navigateAndScrape(ctx context.Context) {
 	// Example of taking a screenshot
 	cd.CaptureScreenshot(ctx, "dashboard.png")
 }
+
+// scrapeInTabs demonstrates reading several pages at once from the tabs of a pool, each tab with its own cookies
+func scrapeInTabs(urls []string) {
+	pool, err := cd.NewPool(cd.PoolOptions{Browser: cd.BrowserOptions{Headless: true, Bypass: true}, MaxTabs: 2, Incognito: true})
+	if err != nil {
+		fmt.Println("Error creating pool:", err)
+		return
+	}
+	// Closing the pool also shuts the browser down
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tab, err := pool.Acquire(context.Background())
+			if err != nil {
+				fmt.Println("Error opening tab:", err)
+				return
+			}
+			defer tab.Release()
+
+			cd.Navigate(tab.Ctx, url)
+			fmt.Println("Retrieved heading:", cd.GetText(tab.Ctx, "h1"))
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// Number of past cycles averaged when estimating a bill that has not been issued yet
+const forecastAverageCycles = 6
+
+// Horizons, in days, that the forecast reports totals for
+var forecastHorizons = []int{30, 60, 90}
+
+const (
+	sourceScraped   = "scraped"
+	sourceRecurring = "recurring"
+	sourceEstimated = "estimated"
+	sourcePastDue   = "past due"
+)
+
+// forecastItem is one expected outflow on a given day
+type forecastItem struct {
+	date   time.Time
+	name   string
+	amount float64
+	source string
+}
+
+type payPeriod struct {
+	start time.Time
+	end   time.Time // exclusive
+	total float64
+}
+
+type forecast struct {
+	start      time.Time
+	days       int
+	items      []forecastItem
+	payPeriods []payPeriod
+}
+
+// buildForecast projects every expected outflow from start through the given number of days. Scraped bills are used as-is, with unpaid past-due ones moved to start, recurring bills from the config follow their schedule and every other bill series seen in the store is estimated from its recent average.
+func buildForecast(bills []billEntry, st *store, start time.Time, days int) forecast {
+	start = truncateDay(start)
+	end := start.AddDate(0, 0, days)
+	fc := forecast{start: start, days: days}
+
+	inRange := func(t time.Time) bool { return !t.Before(start) && t.Before(end) }
+
+	// Unpaid bills that are already past due are the first cash needed, so they count from the first day
+	addIssued := func(due time.Time, name string, amount float64) {
+		source := sourceScraped
+		if due.Before(start) {
+			due, source = start, sourcePastDue
+		}
+		if inRange(due) && amount > 0 {
+			fc.items = append(fc.items, forecastItem{date: due, name: name, amount: amount, source: source})
+		}
+	}
+
+	recurring := map[billKey]bool{}
+	for _, rb := range cfg.Recurring {
+		recurring[billKey{Type: rb.Name}] = true
+		for _, due := range rb.occurrences(start, end, cfg.holidays) {
+			fc.items = append(fc.items, forecastItem{date: due, name: rb.Name, amount: rb.Amount, source: sourceRecurring})
+		}
+	}
+
+	// The latest known due date per bill series, from this run or from the store
+	lastDue := map[billKey]time.Time{}
+	for _, entry := range bills {
+		if recurring[entry.key()] || !entry.bill.retrieved || entry.bill.dueDate == 0 {
+			continue
+		}
+		due := truncateDay(time.Unix(entry.bill.dueDate, 0))
+		// Bills that are already paid need no more cash
+		if entry.bill.status() != statusPaid {
+			addIssued(due, entry.label(), entry.bill.amountDue)
+		}
+		lastDue[entry.key()] = due
+	}
+
+	for _, key := range st.keys() {
+		if recurring[key] {
+			continue
+		}
+		cycles := st.cycles(key)
+		if len(cycles) == 0 {
+			continue
+		}
+		last := cycles[len(cycles)-1]
+		if _, ok := lastDue[key]; !ok {
+			due := truncateDay(time.Unix(last.DueDate, 0))
+			if storedCycleOwed(st, key, last, start) {
+				addIssued(due, key.label(), last.AmountDue)
+			}
+			lastDue[key] = due
+		}
+
+		if len(cycles) > forecastAverageCycles {
+			cycles = cycles[len(cycles)-forecastAverageCycles:]
+		}
+		average := 0.0
+		for _, rec := range cycles {
+			average += rec.AmountDue
+		}
+		average /= float64(len(cycles))
+
+		// Bills that are not issued yet are assumed to fall on the same day of the month as the last known cycle
+		day := lastDue[key].Day()
+		for due := monthlyOnDay(lastDue[key].AddDate(0, 0, 1), day); due.Before(end); due = monthlyOnDay(due.AddDate(0, 0, 1), day) {
+			if inRange(due) {
+				fc.items = append(fc.items, forecastItem{date: due, name: key.label(), amount: average, source: sourceEstimated})
+			}
+		}
+	}
+
+	sort.SliceStable(fc.items, func(i, j int) bool { return fc.items[i].date.Before(fc.items[j].date) })
+
+	anchor, length := payPeriodConfig(start)
+	for ps := alignPayPeriod(anchor, length, start); ps.Before(end); ps = ps.AddDate(0, 0, length) {
+		pp := payPeriod{start: ps, end: ps.AddDate(0, 0, length)}
+		for _, item := range fc.items {
+			if !item.date.Before(pp.start) && item.date.Before(pp.end) {
+				pp.total += item.amount
+			}
+		}
+		fc.payPeriods = append(fc.payPeriods, pp)
+	}
+
+	return fc
+}
+
+// storedCycleOwed reports whether the last stored cycle of a series not fetched in this run still needs cash. A cycle of unknown status is not counted. A past-due cycle only counts when it was unpaid and no later cycle has fallen due since, as a newer bill would carry the balance forward and is estimated on its own.
+func storedCycleOwed(st *store, key billKey, rec billRecord, start time.Time) bool {
+	bill := rec.bill()
+	if m, ok := st.match(key, rec.DueDate); ok {
+		bill.paidReference = m.Reference
+	}
+	due := truncateDay(time.Unix(rec.DueDate, 0))
+	switch bill.status() {
+	case statusUnpaid:
+		return !due.Before(start) || !monthlyOnDay(due.AddDate(0, 0, 1), due.Day()).Before(start)
+	case statusScheduled:
+		return !due.Before(start)
+	default:
+		return false
+	}
+}
+
+// label formats the pay period as an inclusive date range
+func (pp payPeriod) label() string {
+	return pp.start.Format("01/02") + " - " + pp.end.AddDate(0, 0, -1).Format("01/02/2006")
+}
+
+// total returns the outflow due within the first n days of the forecast
+func (fc forecast) total(days int) float64 {
+	end := fc.start.AddDate(0, 0, days)
+	total := 0.0
+	for _, item := range fc.items {
+		if item.date.Before(end) {
+			total += item.amount
+		}
+	}
+	return total
+}
+
+// payPeriodConfig reads the pay schedule from the environment. PAY_PERIOD_ANCHOR is any past or future payday (YYYY-MM-DD) and PAY_PERIOD_DAYS is the length of a pay period, defaulting to 14.
+func payPeriodConfig(fallback time.Time) (time.Time, int) {
+	anchor := fallback
+	if s := os.Getenv("PAY_PERIOD_ANCHOR"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			fmt.Println("Error parsing PAY_PERIOD_ANCHOR:", err)
+		} else {
+			anchor = t
+		}
+	}
+
+	length := 14
+	if s := os.Getenv("PAY_PERIOD_DAYS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			fmt.Println("Error parsing PAY_PERIOD_DAYS:", s)
+		} else {
+			length = n
+		}
+	}
+	return anchor, length
+}
+
+// alignPayPeriod returns the start of the pay period containing t
+func alignPayPeriod(anchor time.Time, length int, t time.Time) time.Time {
+	anchor = truncateDay(anchor)
+	for anchor.After(t) {
+		anchor = anchor.AddDate(0, 0, -length)
+	}
+	for !anchor.AddDate(0, 0, length).After(t) {
+		anchor = anchor.AddDate(0, 0, length)
+	}
+	return anchor
+}
+
+// monthlyOnDay returns the first date on or after t that falls on the given day of the month. Days past the end of a short month fall on its last day.
+func monthlyOnDay(t time.Time, day int) time.Time {
+	t = truncateDay(t)
+	for {
+		due := time.Date(t.Year(), t.Month(), min(day, daysIn(t.Year(), t.Month())), 0, 0, 0, 0, time.Local)
+		if !due.Before(t) {
+			return due
+		}
+		t = firstOfNextMonth(t)
+	}
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.Local).Day()
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func renderForecast(fc forecast) {
+	rows := [][]string{{"Date", "Bill", "Amount ($)", "Source", "Running Total ($)"}}
+	running := 0.0
+	for _, item := range fc.items {
+		running += item.amount
+		rows = append(rows, []string{item.date.Format("Mon 01/02/2006"), item.name, fmt.Sprintf("%.2f", item.amount), item.source, fmt.Sprintf("%.2f", running)})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+
+	rows = [][]string{{"Horizon", "Cash Needed ($)"}}
+	for _, days := range forecastHorizons {
+		if days > fc.days {
+			break
+		}
+		rows = append(rows, []string{fmt.Sprintf("Next %d days", days), fmt.Sprintf("%.2f", fc.total(days))})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+
+	rows = [][]string{{"Pay Period", "Outflow ($)"}}
+	for _, pp := range fc.payPeriods {
+		rows = append(rows, []string{pp.label(), fmt.Sprintf("%.2f", pp.total)})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestForecastPayPeriods(t *testing.T) {
+	t.Setenv("PAY_PERIOD_ANCHOR", "2026-05-01")
+	t.Setenv("PAY_PERIOD_DAYS", "14")
+
+	car := recurringBill{Name: "Car", Amount: 422.94, Rule: ruleMonthly, Day: 17}
+	if err := car.validate(); err != nil {
+		t.Fatal(err)
+	}
+	saved := cfg
+	cfg = &config{Recurring: []recurringBill{car}, holidays: map[string]bool{}}
+	t.Cleanup(func() { cfg = saved })
+
+	cycle := func(name string, due string, amount float64, status string) billRecord {
+		return billRecord{Type: name, AmountDue: amount, DueDate: date(due).Unix(), RetrievedAt: date(due).Unix(), Status: status}
+	}
+	st := &store{Records: []billRecord{
+		cycle("Power", "2026-03-10", 80, statusPaid),
+		cycle("Power", "2026-04-10", 100, statusPaid),
+		cycle("Gas", "2026-04-12", 40, statusPaid),
+		cycle("Water", "2026-03-25", 50, statusPaid),
+		cycle("Water", "2026-04-25", 60, statusUnpaid),    // past due and still the latest cycle, so owed from the first day
+		cycle("Internet", "2026-02-15", 70, statusUnpaid), // long past due, later cycles have been issued since
+		cycle("Trash", "2026-05-20", 30, statusUnknown),   // not known to be owed
+	}}
+	bills := []billEntry{
+		{name: "Power", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 120, dueDate: date("2026-05-10").Unix()}},
+		{name: "Gas", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 0, dueDate: date("2026-05-12").Unix()}},
+	}
+
+	fc := buildForecast(bills, st, date("2026-05-01"), 60)
+
+	want := []struct {
+		start string
+		total float64
+	}{
+		{"2026-05-01", 60 + 120},         // Water past due, Power scraped
+		{"2026-05-15", 70 + 422.94 + 55}, // Internet estimated, Car, Water estimated
+		{"2026-05-29", 90},               // Power estimated
+		{"2026-06-12", 40 + 70 + 422.94 + 30 + 55},
+		{"2026-06-26", 0},
+	}
+	if len(fc.payPeriods) != len(want) {
+		t.Fatalf("got %d pay periods, want %d", len(fc.payPeriods), len(want))
+	}
+	for i, w := range want {
+		pp := fc.payPeriods[i]
+		if pp.start.Format(dateLayout) != w.start || math.Abs(pp.total-w.total) > 0.005 {
+			t.Errorf("pay period %d = %s %.2f, want %s %.2f", i, pp.start.Format(dateLayout), pp.total, w.start, w.total)
+		}
+	}
+
+	sources := map[string]int{}
+	for _, item := range fc.items {
+		sources[item.source]++
+	}
+	if sources[sourcePastDue] != 1 || sources[sourceScraped] != 1 || sources[sourceRecurring] != 2 || sources[sourceEstimated] != 7 {
+		t.Errorf("items by source = %v", sources)
+	}
+	if got, want := fc.total(30), 60+120+70+422.94+55.0; math.Abs(got-want) > 0.005 {
+		t.Errorf("30 day total = %.2f, want %.2f", got, want)
+	}
+}
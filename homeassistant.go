@@ -0,0 +1,216 @@
+package main
+
+import (
+	"billburner/mqtt"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// mqttSink publishes bills as retained MQTT state along with Home Assistant discovery config, so every bill shows up as a device with sensors
+type mqttSink struct {
+	client    *mqtt.Client
+	prefix    string // state topics live under <prefix>/<bill>/
+	discovery string // Home Assistant discovery prefix
+}
+
+// haSensor is the discovery config for one sensor. See https://www.home-assistant.io/integrations/sensor.mqtt/
+type haSensor struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	StateTopic        string   `json:"state_topic"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	UnitOfMeasurement string   `json:"unit_of_measurement,omitempty"`
+	Icon              string   `json:"icon,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+}
+
+// dialMQTT connects to the broker in MQTT_URL, e.g. tcp://homeassistant.local:1883 or ssl://broker:8883. It returns nil when no broker is configured.
+func dialMQTT() (*mqttSink, error) {
+	raw := os.Getenv("MQTT_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid MQTT_URL %q", raw)
+	}
+
+	opts := mqtt.Options{
+		Addr:     u.Host,
+		ClientID: "billburner",
+		Username: os.Getenv("MQTT_USERNAME"),
+		Password: os.Getenv("MQTT_PASSWORD"),
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		opts.TLS = true
+	default:
+		return nil, fmt.Errorf("unsupported MQTT_URL scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		port := "1883"
+		if opts.TLS {
+			port = "8883"
+		}
+		opts.Addr = u.Host + ":" + port
+	}
+	return newMQTTSink(opts)
+}
+
+func newMQTTSink(opts mqtt.Options) (*mqttSink, error) {
+	client, err := mqtt.Dial(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &mqttSink{client: client, prefix: "billburner", discovery: "homeassistant"}
+	if prefix := os.Getenv("MQTT_PREFIX"); prefix != "" {
+		s.prefix = prefix
+	}
+	if discovery := os.Getenv("MQTT_DISCOVERY_PREFIX"); discovery != "" {
+		s.discovery = discovery
+	}
+	return s, nil
+}
+
+func (s *mqttSink) Close() {
+	s.client.Close()
+}
+
+// haUnknown is the state Home Assistant shows as unknown. An empty payload cannot be used, since a retained empty message clears the topic instead.
+const haUnknown = "None"
+
+// objectID turns a bill label into the id used in topics and unique ids, e.g. "Power (Rental)" becomes power_rental
+func objectID(key billKey) string {
+	var sb strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(key.label()) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			underscore = false
+		} else if !underscore && sb.Len() > 0 {
+			sb.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "_")
+}
+
+// publishBill sends the discovery config and the current state of one bill. Everything is retained so Home Assistant picks it up after a restart.
+func (s *mqttSink) publishBill(entry billEntry) error {
+	id := objectID(entry.key())
+	base := s.prefix + "/" + id
+	device := haDevice{
+		Identifiers:  []string{"billburner_" + id},
+		Name:         entry.label(),
+		Manufacturer: "BillBurner",
+		Model:        entry.provider,
+	}
+
+	// Without a due date both date sensors are unknown rather than 1970 and a large negative day count
+	dueDate, daysUntilDue := haUnknown, haUnknown
+	if entry.bill.dueDate != 0 {
+		dueDate = time.Unix(entry.bill.dueDate, 0).Format(time.RFC3339)
+		daysUntilDue = strconv.Itoa(daysUntil(entry.bill.dueDate))
+	}
+
+	sensors := []struct {
+		field  string
+		config haSensor
+		state  string
+	}{
+		{"amount", haSensor{Name: "Amount due", DeviceClass: "monetary", UnitOfMeasurement: "USD"}, fmt.Sprintf("%.2f", entry.bill.amountDue)},
+		{"due_date", haSensor{Name: "Due date", DeviceClass: "timestamp"}, dueDate},
+		{"days_until_due", haSensor{Name: "Days until due", DeviceClass: "duration", UnitOfMeasurement: "d"}, daysUntilDue},
+		{"status", haSensor{Name: "Status", Icon: "mdi:cash-check"}, entry.bill.status()},
+	}
+
+	for _, sensor := range sensors {
+		config := sensor.config
+		config.UniqueID = "billburner_" + id + "_" + sensor.field
+		config.StateTopic = base + "/" + sensor.field
+		config.Device = device
+
+		payload, err := json.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("error encoding discovery config: %v", err)
+		}
+		if err := s.client.Publish(s.discovery+"/sensor/"+config.UniqueID+"/config", payload, true); err != nil {
+			return err
+		}
+		if err := s.client.Publish(config.StateTopic, []byte(sensor.state), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mqttCommand publishes the latest stored bills to the configured broker, or with "mqtt test" to an embedded broker and prints what was published
+func mqttCommand(args []string) error {
+	st, err := loadStore(storePath())
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 && args[0] == "test" {
+		broker, err := mqtt.NewBroker("127.0.0.1:0")
+		if err != nil {
+			return err
+		}
+		defer broker.Close()
+
+		sink, err := newMQTTSink(mqtt.Options{Addr: broker.Addr(), ClientID: "billburner-test"})
+		if err != nil {
+			return err
+		}
+		defer sink.Close()
+		for _, entry := range st.latestBills() {
+			if err := sink.publishBill(entry); err != nil {
+				return err
+			}
+		}
+
+		retained := broker.Retained()
+		topics := make([]string, 0, len(retained))
+		for topic := range retained {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		rows := [][]string{{"Topic", "Payload"}}
+		for _, topic := range topics {
+			rows = append(rows, []string{topic, string(retained[topic])})
+		}
+		pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+		return nil
+	}
+
+	sink, err := dialMQTT()
+	if err != nil {
+		return err
+	}
+	if sink == nil {
+		return fmt.Errorf("MQTT_URL is not set")
+	}
+	defer sink.Close()
+	for _, entry := range st.latestBills() {
+		if err := sink.publishBill(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"billburner/mqtt"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestObjectID(t *testing.T) {
+	tests := []struct {
+		key  billKey
+		want string
+	}{
+		{billKey{"Power", ""}, "power"},
+		{billKey{"Power", "Rental"}, "power_rental"},
+		{billKey{"Internet", "Mom's House #2"}, "internet_mom_s_house_2"},
+	}
+	for _, tt := range tests {
+		if got := objectID(tt.key); got != tt.want {
+			t.Errorf("objectID(%v) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+// startSink starts an embedded broker and connects a sink with the default topic prefixes to it
+func startSink(t *testing.T) (*mqtt.Broker, *mqttSink) {
+	t.Helper()
+	t.Setenv("MQTT_PREFIX", "")
+	t.Setenv("MQTT_DISCOVERY_PREFIX", "")
+
+	broker, err := mqtt.NewBroker("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	sink, err := newMQTTSink(mqtt.Options{Addr: broker.Addr(), ClientID: "test", Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sink.Close)
+	return broker, sink
+}
+
+func TestPublishBillDiscovery(t *testing.T) {
+	broker, sink := startSink(t)
+
+	due := time.Date(2026, 5, 17, 0, 0, 0, 0, time.Local)
+	entry := billEntry{provider: "ameren", name: "Power", account: "Rental",
+		bill: &Bill{amountDue: 120.5, amountParsed: true, dueDate: due.Unix(), retrieved: true}}
+	if err := sink.publishBill(entry); err != nil {
+		t.Fatal(err)
+	}
+	retained := broker.Retained()
+
+	states := map[string]string{
+		"billburner/power_rental/amount":   "120.50",
+		"billburner/power_rental/due_date": due.Format(time.RFC3339),
+		"billburner/power_rental/status":   statusUnpaid,
+	}
+	for topic, want := range states {
+		if got := string(retained[topic]); got != want {
+			t.Errorf("%s = %q, want %q", topic, got, want)
+		}
+	}
+
+	var config haSensor
+	if err := json.Unmarshal(retained["homeassistant/sensor/billburner_power_rental_amount/config"], &config); err != nil {
+		t.Fatalf("amount discovery config: %v", err)
+	}
+	if config.StateTopic != "billburner/power_rental/amount" || config.UniqueID != "billburner_power_rental_amount" ||
+		config.DeviceClass != "monetary" || config.UnitOfMeasurement != "USD" {
+		t.Errorf("amount discovery config = %+v", config)
+	}
+	if len(config.Device.Identifiers) != 1 || config.Device.Identifiers[0] != "billburner_power_rental" || config.Device.Name != "Power (Rental)" || config.Device.Model != "ameren" {
+		t.Errorf("device = %+v", config.Device)
+	}
+
+	// One config and one state topic for each of the four sensors
+	if len(retained) != 8 {
+		t.Errorf("published %d retained topics, want 8", len(retained))
+	}
+}
+
+func TestPublishBillDueDate(t *testing.T) {
+	due := time.Now().AddDate(0, 0, 10).Add(time.Hour)
+	tests := []struct {
+		name         string
+		dueDate      int64
+		wantDueDate  string
+		wantDaysLeft string
+	}{
+		{"due date known", due.Unix(), due.Format(time.RFC3339), "10"},
+		{"due date not found", 0, haUnknown, haUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker, sink := startSink(t)
+			entry := billEntry{provider: "spire", name: "Gas",
+				bill: &Bill{amountDue: 40, amountParsed: true, dueDate: tt.dueDate, retrieved: true}}
+			if err := sink.publishBill(entry); err != nil {
+				t.Fatal(err)
+			}
+			retained := broker.Retained()
+
+			if got := string(retained["billburner/gas/due_date"]); got != tt.wantDueDate {
+				t.Errorf("due_date = %q, want %q", got, tt.wantDueDate)
+			}
+			if got := string(retained["billburner/gas/days_until_due"]); got != tt.wantDaysLeft {
+				t.Errorf("days_until_due = %q, want %q", got, tt.wantDaysLeft)
+			}
+		})
+	}
+}
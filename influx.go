@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	influxhttp "github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/influxdata/influxdb-client-go/v2/domain"
+)
+
+// How hard a run tries to write its batch before spooling it
+const (
+	influxAttempts = 3
+	influxTimeout  = 10 * time.Second
+)
+
+// influxRetryDelay is the wait before the second attempt, doubled for each one after
+var influxRetryDelay = time.Second
+
+// influxBatch collects the points of one run and writes them together at the end. Points that cannot be written for now are appended to a spool file in line protocol and replayed on the next run; points the server turns down are moved to a rejected file instead.
+type influxBatch struct {
+	client   influxdb2.Client
+	writeAPI api.WriteAPIBlocking
+	spool    string
+	rejected string
+	offline  bool // set when the health check failed, so the run goes straight to the spool
+
+	mu     sync.Mutex
+	points []*write.Point
+}
+
+func influxSpoolPath() string {
+	if path := os.Getenv("INFLUXDB_SPOOL"); path != "" {
+		return path
+	}
+	return "influx-spool.lp"
+}
+
+func newInfluxBatch() *influxBatch {
+	client := influxdb2.NewClient(os.Getenv("INFLUXDB_URL"), os.Getenv("INFLUXDB_TOKEN"))
+	return &influxBatch{
+		client:   client,
+		writeAPI: client.WriteAPIBlocking(os.Getenv("INFLUXDB_ORG"), os.Getenv("INFLUXDB_BUCKET")),
+		spool:    influxSpoolPath(),
+		rejected: influxSpoolPath() + ".rejected",
+	}
+}
+
+// checkHealth asks the server whether it is ready to take writes. A failed check marks the batch offline.
+func (ib *influxBatch) checkHealth() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	health, err := ib.client.Health(ctx)
+	if err == nil && health.Status != domain.HealthCheckStatusPass {
+		msg := string(health.Status)
+		if health.Message != nil {
+			msg += ": " + *health.Message
+		}
+		err = errors.New(msg)
+	}
+	if err != nil {
+		ib.offline = true
+		return fmt.Errorf("InfluxDB is not healthy, points will be spooled to %s: %v", ib.spool, err)
+	}
+	return nil
+}
+
+func (ib *influxBatch) add(point *write.Point) {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+	ib.points = append(ib.points, point)
+}
+
+// flush writes spooled points from earlier runs and then this run's batch. Whatever cannot be written is left in the spool.
+func (ib *influxBatch) flush() error {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+
+	lines, err := ib.readSpool()
+	if err != nil {
+		return err
+	}
+	for _, p := range ib.points {
+		lines = append(lines, write.PointToLineProtocol(p, time.Nanosecond))
+	}
+	ib.points = nil
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if !ib.offline {
+		if lines, err = ib.send(lines); len(lines) == 0 {
+			if err := os.Remove(ib.spool); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("error clearing spool %s: %v", ib.spool, err)
+			}
+			return err
+		}
+	}
+
+	if err := ib.writeSpool(lines); err != nil {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("error writing to InfluxDB, %d points spooled to %s: %v", len(lines), ib.spool, err)
+	}
+	fmt.Printf("InfluxDB offline, %d points spooled to %s\n", len(lines), ib.spool)
+	return nil
+}
+
+// spoolPending saves the points collected so far without trying the server. It is used when a run is cut short.
+func (ib *influxBatch) spoolPending() {
+	ib.mu.Lock()
+	defer ib.mu.Unlock()
+	if len(ib.points) == 0 {
+		return
+	}
+	lines, err := ib.readSpool()
+	if err == nil {
+		for _, p := range ib.points {
+			lines = append(lines, write.PointToLineProtocol(p, time.Nanosecond))
+		}
+		err = ib.writeSpool(lines)
+	}
+	if err != nil {
+		fmt.Println("Error spooling InfluxDB points:", err)
+	}
+	ib.points = nil
+}
+
+// send writes lines and returns the ones to spool for the next run, along with the error that kept them back. Lines the server turns down are moved to the rejected file rather than spooled, since they would fail the same way on every run and hold up the points after them.
+func (ib *influxBatch) send(lines []string) ([]string, error) {
+	err := ib.writeLines(lines)
+	if err == nil {
+		return nil, nil
+	}
+	status := influxStatus(err)
+	if !influxPermanent(status) {
+		return lines, err
+	}
+
+	rejected := lines
+	var pending []string
+	var pendingErr error
+	if status == http.StatusBadRequest || status == http.StatusRequestEntityTooLarge || status == http.StatusUnprocessableEntity {
+		// A bad line fails the whole request, so send them one at a time to find the ones at fault
+		rejected = nil
+		for i, line := range lines {
+			lineErr := ib.writeLines([]string{line})
+			if lineErr == nil {
+				continue
+			}
+			if !influxPermanent(influxStatus(lineErr)) {
+				pending, pendingErr = lines[i:], lineErr
+				break
+			}
+			rejected = append(rejected, line)
+			err = lineErr
+		}
+	}
+
+	if len(rejected) > 0 {
+		if rerr := ib.reject(rejected); rerr != nil {
+			return append(rejected, pending...), rerr
+		}
+		fmt.Printf("InfluxDB rejected %d points, moved to %s: %v\n", len(rejected), ib.rejected, err)
+	}
+	return pending, pendingErr
+}
+
+// influxStatus is the HTTP status of a failed write, or 0 when the server was not reached
+func influxStatus(err error) int {
+	var herr *influxhttp.Error
+	if errors.As(err, &herr) {
+		return herr.StatusCode
+	}
+	return 0
+}
+
+// influxPermanent reports whether a write failed for good: a client error such as a bad token, a missing bucket or malformed line protocol. Network errors, server errors, timeouts and rate limits may pass.
+func influxPermanent(status int) bool {
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// writeLines sends the lines in one request, retrying with a growing delay unless the server turned them down for good
+func (ib *influxBatch) writeLines(lines []string) error {
+	var err error
+	delay := influxRetryDelay
+	for attempt := 1; attempt <= influxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), influxTimeout)
+		err = ib.writeAPI.WriteRecord(ctx, lines...)
+		cancel()
+		if err == nil || influxPermanent(influxStatus(err)) {
+			return err
+		}
+		if attempt < influxAttempts {
+			fmt.Printf("Error writing to InfluxDB (attempt %d of %d): %v\n", attempt, influxAttempts, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+func (ib *influxBatch) readSpool() ([]string, error) {
+	f, err := os.Open(ib.spool)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading spool %s: %v", ib.spool, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading spool %s: %v", ib.spool, err)
+	}
+	return lines, nil
+}
+
+// writeSpool replaces the spool with lines, through a temporary file so a crash never loses what was already spooled
+func (ib *influxBatch) writeSpool(lines []string) error {
+	tmp := ib.spool + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing spool %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, ib.spool); err != nil {
+		return fmt.Errorf("error writing spool %s: %v", ib.spool, err)
+	}
+	return nil
+}
+
+// reject appends lines to the rejected file, where they are kept for a look by hand
+func (ib *influxBatch) reject(lines []string) error {
+	f, err := os.OpenFile(ib.rejected, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.WriteString(strings.Join(lines, "\n") + "\n")
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error writing rejected points to %s: %v", ib.rejected, err)
+	}
+	return nil
+}
+
+func (ib *influxBatch) Close() {
+	ib.client.Close()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// fakeInflux answers writes with status, except that with http.StatusBadRequest only requests holding a line with "bad" are turned down
+func fakeInflux(t *testing.T, status int) (*influxBatch, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var written []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		refuse := status != http.StatusNoContent
+		if status == http.StatusBadRequest {
+			refuse = strings.Contains(string(body), "bad")
+		}
+		if refuse {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			io.WriteString(w, `{"code":"invalid","message":"refused"}`)
+			return
+		}
+		mu.Lock()
+		written = append(written, lines...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	delay := influxRetryDelay
+	influxRetryDelay = time.Millisecond
+	t.Cleanup(func() { influxRetryDelay = delay })
+
+	dir := t.TempDir()
+	client := influxdb2.NewClient(srv.URL, "token")
+	t.Cleanup(client.Close)
+	ib := &influxBatch{
+		client:   client,
+		writeAPI: client.WriteAPIBlocking("org", "bills"),
+		spool:    filepath.Join(dir, "spool.lp"),
+		rejected: filepath.Join(dir, "spool.lp.rejected"),
+	}
+	return ib, &written
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestInfluxFlushSpool(t *testing.T) {
+	spooled := []string{"bill,type=Gas amount=40 1", "bill,type=Power bad 2", "bill,type=Water amount=62.1 3"}
+
+	tests := []struct {
+		name         string
+		status       int
+		wantWritten  int
+		wantSpooled  int
+		wantRejected int
+		wantErr      bool
+	}{
+		{"accepted", http.StatusNoContent, 3, 0, 0, false},
+		{"bad line rejected, the rest written", http.StatusBadRequest, 2, 0, 1, false},
+		{"bad token rejects everything", http.StatusUnauthorized, 0, 0, 3, false},
+		{"server error keeps everything spooled", http.StatusServiceUnavailable, 0, 3, 0, true},
+		{"rate limit keeps everything spooled", http.StatusTooManyRequests, 0, 3, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ib, written := fakeInflux(t, tt.status)
+			if err := ib.writeSpool(spooled); err != nil {
+				t.Fatal(err)
+			}
+
+			err := ib.flush()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("flush() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(*written) != tt.wantWritten {
+				t.Errorf("written = %q, want %d lines", *written, tt.wantWritten)
+			}
+			if got := readLines(t, ib.spool); len(got) != tt.wantSpooled {
+				t.Errorf("spool = %q, want %d lines", got, tt.wantSpooled)
+			}
+			if got := readLines(t, ib.rejected); len(got) != tt.wantRejected {
+				t.Errorf("rejected = %q, want %d lines", got, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestInfluxRejectedLineDoesNotBlockLaterFlushes(t *testing.T) {
+	ib, written := fakeInflux(t, http.StatusBadRequest)
+	if err := ib.writeSpool([]string{"bill,type=Power bad 1"}); err != nil {
+		t.Fatal(err)
+	}
+	ib.flush()
+
+	if err := ib.writeSpool([]string{"bill,type=Gas amount=40 2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ib.flush(); err != nil {
+		t.Fatalf("second flush: %v", err)
+	}
+	if len(*written) != 1 || readLines(t, ib.spool) != nil {
+		t.Errorf("written = %q, spool = %q, want the good line written and the spool cleared", *written, readLines(t, ib.spool))
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Plain-text accounting formats the journal exporter can write
+const (
+	journalLedger    = "ledger"
+	journalHledger   = "hledger"
+	journalBeancount = "beancount"
+)
+
+// journalConfig is one plain-text accounting file that every billing cycle is appended to as a payable.
+//
+// Example:
+//
+//	{
+//	  "format": "beancount",
+//	  "path": "/home/alex/finances/bills.beancount",
+//	  "expenses": {"Power": "Expenses:Utilities:Electric", "Mortgage": "Expenses:Housing:Mortgage"},
+//	  "liabilities": {"ameren": "Liabilities:Payable:Ameren"}
+//	}
+type journalConfig struct {
+	Format      string            `json:"format"`
+	Path        string            `json:"path"`
+	Currency    string            `json:"currency,omitempty"`    // USD by default
+	Expenses    map[string]string `json:"expenses,omitempty"`    // bill type to expense account, Expenses:Bills:<type> by default
+	Liabilities map[string]string `json:"liabilities,omitempty"` // provider id to liability account, Liabilities:Payable:<provider> by default
+}
+
+// journalEntry is one billing cycle ready to be written in any format
+type journalEntry struct {
+	id        string
+	date      time.Time
+	payee     string
+	narration string
+	expense   string
+	liability string
+	amount    float64
+	account   string
+	statement string
+}
+
+var journalIDRe = regexp.MustCompile(`billburner-id:\s*"?([^"\s]+)`)
+var beancountOpenRe = regexp.MustCompile(`(?m)^\d{4}-\d{2}-\d{2}\s+open\s+(\S+)`)
+
+func (jc *journalConfig) validate() error {
+	switch jc.Format {
+	case journalLedger, journalHledger, journalBeancount:
+	default:
+		return fmt.Errorf("unknown format %q", jc.Format)
+	}
+	if jc.Path == "" {
+		return fmt.Errorf("no path for %s journal", jc.Format)
+	}
+	if jc.Currency == "" {
+		jc.Currency = "USD"
+	}
+	return nil
+}
+
+// accountPart turns a bill type or provider into an account name component, which in beancount must start with a capital letter and hold no spaces
+func accountPart(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			sb.WriteRune(r)
+		}
+	}
+	part := sb.String()
+	if part == "" {
+		return "Unknown"
+	}
+	return strings.ToUpper(part[:1]) + part[1:]
+}
+
+func (jc *journalConfig) expenseAccount(billType string) string {
+	if acct, ok := jc.Expenses[billType]; ok {
+		return acct
+	}
+	return "Expenses:Bills:" + accountPart(billType)
+}
+
+func (jc *journalConfig) liabilityAccount(provider string) string {
+	if acct, ok := jc.Liabilities[provider]; ok {
+		return acct
+	}
+	return "Liabilities:Payable:" + accountPart(provider)
+}
+
+// journalID identifies a billing cycle across exports
+func journalID(key billKey, dueDate int64) string {
+	id := key.Type
+	if key.Account != "" {
+		id += "/" + key.Account
+	}
+	id += "/" + time.Unix(dueDate, 0).Format(dateLayout)
+	return strings.ReplaceAll(id, " ", "_")
+}
+
+// journalEntries builds an entry for every stored billing cycle that charged something, oldest first
+func journalEntries(jc *journalConfig, st *store) []journalEntry {
+	var entries []journalEntry
+	for _, key := range st.keys() {
+		for _, rec := range st.cycles(key) {
+			amount := rec.owed()
+			if amount <= 0 {
+				continue
+			}
+
+			// Recurring bills have no provider, the bill itself is owed to
+			provider := rec.Provider
+			if provider == "" {
+				provider = key.Type
+			}
+
+			entry := journalEntry{
+				id:        journalID(key, rec.DueDate),
+				date:      time.Unix(rec.DueDate, 0),
+				payee:     accountPart(provider),
+				narration: key.label() + " bill",
+				expense:   jc.expenseAccount(key.Type),
+				liability: jc.liabilityAccount(provider),
+				amount:    amount,
+				account:   key.Account,
+			}
+			if sr, ok := st.statement(rec.Provider, key.Account, rec.DueDate); ok {
+				entry.statement = sr.SHA256[:12]
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].date.Before(entries[j].date) })
+	return entries
+}
+
+// statement finds the archived statement for a billing cycle
+func (st *store) statement(provider, account string, dueDate int64) (statementRecord, bool) {
+	for _, sr := range st.Statements {
+		if sr.Provider == provider && sr.Account == account && sr.DueDate == dueDate {
+			return sr, true
+		}
+	}
+	return statementRecord{}, false
+}
+
+// exportJournal appends the cycles the journal does not have yet. Cycles already written are recognised by their billburner-id metadata, so running it again adds nothing. It returns how many transactions were written.
+func exportJournal(jc *journalConfig, st *store) (int, error) {
+	existing, err := os.ReadFile(jc.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("error reading journal %s: %v", jc.Path, err)
+	}
+
+	written := map[string]bool{}
+	for _, m := range journalIDRe.FindAllStringSubmatch(string(existing), -1) {
+		written[m[1]] = true
+	}
+	opened := map[string]bool{}
+	for _, m := range beancountOpenRe.FindAllStringSubmatch(string(existing), -1) {
+		opened[m[1]] = true
+	}
+
+	var sb strings.Builder
+	count := 0
+	for _, entry := range journalEntries(jc, st) {
+		if written[entry.id] {
+			continue
+		}
+		written[entry.id] = true
+
+		if jc.Format == journalBeancount {
+			// Beancount refuses postings to accounts that were never opened
+			for _, acct := range []string{entry.expense, entry.liability} {
+				if !opened[acct] {
+					opened[acct] = true
+					fmt.Fprintf(&sb, "%s open %s\n\n", entry.date.Format(dateLayout), acct)
+				}
+			}
+		}
+		jc.writeEntry(&sb, entry)
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	f, err := os.OpenFile(jc.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("error opening journal %s: %v", jc.Path, err)
+	}
+	defer f.Close()
+
+	out := sb.String()
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n\n") {
+		out = "\n" + out
+	}
+	if _, err := f.WriteString(out); err != nil {
+		return 0, fmt.Errorf("error writing journal %s: %v", jc.Path, err)
+	}
+	return count, nil
+}
+
+func (jc *journalConfig) writeEntry(sb *strings.Builder, e journalEntry) {
+	switch jc.Format {
+	case journalBeancount:
+		fmt.Fprintf(sb, "%s * %q %q\n", e.date.Format(dateLayout), e.payee, e.narration)
+		fmt.Fprintf(sb, "  billburner-id: %q\n", e.id)
+		if e.account != "" {
+			fmt.Fprintf(sb, "  account: %q\n", e.account)
+		}
+		if e.statement != "" {
+			fmt.Fprintf(sb, "  statement: %q\n", e.statement)
+		}
+		fmt.Fprintf(sb, "  %-40s %.2f %s\n", e.expense, e.amount, jc.Currency)
+		fmt.Fprintf(sb, "  %s\n\n", e.liability)
+	default:
+		// ledger and hledger share the syntax apart from the date, and both read "; key: value" comments as metadata
+		date := e.date.Format("2006/01/02")
+		if jc.Format == journalHledger {
+			date = e.date.Format(dateLayout)
+		}
+		fmt.Fprintf(sb, "%s %s  ; %s\n", date, e.payee, e.narration)
+		fmt.Fprintf(sb, "    ; billburner-id: %s\n", e.id)
+		if e.account != "" {
+			fmt.Fprintf(sb, "    ; account: %s\n", e.account)
+		}
+		if e.statement != "" {
+			fmt.Fprintf(sb, "    ; statement: %s\n", e.statement)
+		}
+		fmt.Fprintf(sb, "    %-40s  %s\n", e.expense, jc.formatAmount(e.amount))
+		fmt.Fprintf(sb, "    %s\n\n", e.liability)
+	}
+}
+
+func (jc *journalConfig) formatAmount(amount float64) string {
+	if jc.Currency == "USD" {
+		return fmt.Sprintf("$%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, jc.Currency)
+}
+
+// exportJournals appends new cycles to every configured journal
+func exportJournals(st *store) {
+	for i := range cfg.Journals {
+		jc := &cfg.Journals[i]
+		n, err := exportJournal(jc, st)
+		if err != nil {
+			fmt.Println("Error exporting journal:", err)
+			continue
+		}
+		if n > 0 {
+			fmt.Printf("Added %d transactions to %s\n", n, jc.Path)
+		}
+	}
+}
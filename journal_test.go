@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportJournalTwice(t *testing.T) {
+	st := &store{Records: []billRecord{
+		{Type: "Power", Provider: "ameren", Account: "Rental House", AmountDue: 120.5, DueDate: date("2026-04-17").Unix(), RetrievedAt: 1},
+		{Type: "Power", Provider: "ameren", Account: "Rental House", AmountDue: 98.25, DueDate: date("2026-05-17").Unix(), RetrievedAt: 2},
+		{Type: "Rent", AmountDue: 1500, DueDate: date("2026-05-01").Unix(), RetrievedAt: 2},
+		{Type: "Gas", Provider: "spire", AmountDue: 0, DueDate: date("2026-05-08").Unix(), RetrievedAt: 2}, // nothing charged
+	}}
+	newCycle := billRecord{Type: "Power", Provider: "ameren", Account: "Rental House", AmountDue: 101, DueDate: date("2026-06-17").Unix(), RetrievedAt: 3}
+
+	tests := []struct {
+		format string
+		want   []string // lines the first export must write
+	}{
+		{journalLedger, []string{
+			"2026/05/01 Rent  ; Rent bill",
+			"    ; billburner-id: Power/Rental_House/2026-05-17",
+			"    Expenses:Bills:Power                      $98.25",
+		}},
+		{journalHledger, []string{
+			"2026-05-01 Rent  ; Rent bill",
+			"    ; account: Rental House",
+			"    Liabilities:Payable:Ameren",
+		}},
+		{journalBeancount, []string{
+			"2026-04-17 open Expenses:Bills:Power",
+			"2026-05-01 open Liabilities:Payable:Rent",
+			`2026-05-17 * "Ameren" "Power (Rental House) bill"`,
+			`  billburner-id: "Power/Rental_House/2026-05-17"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			jc := &journalConfig{Format: tt.format, Path: filepath.Join(t.TempDir(), "bills.journal")}
+			if err := jc.validate(); err != nil {
+				t.Fatal(err)
+			}
+			s := &store{Records: append([]billRecord(nil), st.Records...)}
+
+			if n, err := exportJournal(jc, s); err != nil || n != 3 {
+				t.Fatalf("first export = %d, %v, want 3 transactions", n, err)
+			}
+			first := readJournal(t, jc.Path)
+			for _, line := range tt.want {
+				if !strings.Contains(first, line+"\n") {
+					t.Errorf("journal is missing %q:\n%s", line, first)
+				}
+			}
+
+			if n, err := exportJournal(jc, s); err != nil || n != 0 {
+				t.Fatalf("second export = %d, %v, want nothing written", n, err)
+			}
+			if got := readJournal(t, jc.Path); got != first {
+				t.Errorf("second export changed the journal:\n%s", got)
+			}
+
+			// A later cycle is appended, and beancount opens no account a second time
+			s.Records = append(s.Records, newCycle)
+			if n, err := exportJournal(jc, s); err != nil || n != 1 {
+				t.Fatalf("export after a new cycle = %d, %v, want 1 transaction", n, err)
+			}
+			got := readJournal(t, jc.Path)
+			if !strings.HasPrefix(got, first) || strings.Count(got, "Power/Rental_House/2026-06-17") != 1 {
+				t.Errorf("new cycle not appended once:\n%s", got)
+			}
+			if n := strings.Count(got, " open "); tt.format == journalBeancount && n != 4 {
+				t.Errorf("journal has %d open directives, want 4:\n%s", n, got)
+			}
+		})
+	}
+}
+
+func readJournal(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+// loan describes an amortizing loan such as a mortgage or car loan. Name matches the bill the loan is paid through.
+//
+// Example:
+//
+//	{"name": "Mortgage", "principal": 240000, "rate": 6.125, "termMonths": 360, "start": "2023-08-01", "escrow": 412.18}
+type loan struct {
+	Name       string  `json:"name"`
+	Account    string  `json:"account,omitempty"` // account nickname, when the bill type has several accounts
+	Principal  float64 `json:"principal"`         // original amount borrowed
+	Rate       float64 `json:"rate"`              // annual interest rate in percent
+	TermMonths int     `json:"termMonths"`        // number of monthly payments
+	Start      string  `json:"start"`             // first payment date
+	Escrow     float64 `json:"escrow,omitempty"`  // taxes and insurance collected with each payment
+
+	start time.Time
+}
+
+// loanPayment is one row of an amortization schedule
+type loanPayment struct {
+	number    int
+	date      time.Time
+	payment   float64 // principal and interest, without escrow
+	principal float64
+	interest  float64
+	escrow    float64
+	balance   float64 // principal remaining after this payment
+}
+
+// loanSummary is the state of a loan as of a run, reconciled against what the provider reported
+type loanSummary struct {
+	key              billKey
+	next             loanPayment // the next scheduled payment, zero once the loan is paid off
+	paymentsMade     int
+	balance          float64 // remaining principal, scraped from the provider when available
+	scheduledBalance float64 // remaining principal according to the schedule
+	interestToDate   float64
+	payoffDate       time.Time
+	scrapedPayment   float64 // amount due reported by the provider, zero when not retrieved
+}
+
+func (l *loan) validate() error {
+	if l.Principal <= 0 {
+		return fmt.Errorf("principal must be positive")
+	}
+	if l.Rate < 0 {
+		return fmt.Errorf("rate cannot be negative")
+	}
+	if l.TermMonths <= 0 {
+		return fmt.Errorf("termMonths must be positive")
+	}
+	var err error
+	if l.start, err = parseConfigDate(l.Start); err != nil {
+		return fmt.Errorf("invalid start date: %v", err)
+	}
+	return nil
+}
+
+// monthlyPayment returns the fixed principal and interest payment that pays the balance off over the given number of months
+func monthlyPayment(balance, annualRate float64, months int) float64 {
+	r := annualRate / 100 / 12
+	if r == 0 {
+		return balance / float64(months)
+	}
+	return balance * r / (1 - math.Pow(1+r, -float64(months)))
+}
+
+// schedule returns the full amortization schedule of the loan
+func (l loan) schedule() []loanPayment {
+	r := l.Rate / 100 / 12
+	payment := monthlyPayment(l.Principal, l.Rate, l.TermMonths)
+	balance := l.Principal
+
+	rows := make([]loanPayment, 0, l.TermMonths)
+	for n := 1; n <= l.TermMonths && balance > 0.005; n++ {
+		interest := roundCents(balance * r)
+		principal := roundCents(payment) - interest
+		// The final payment clears whatever rounding left behind
+		if n == l.TermMonths || principal > balance {
+			principal = balance
+		}
+		balance = roundCents(balance - principal)
+
+		rows = append(rows, loanPayment{
+			number:    n,
+			date:      addMonthsOnDay(l.start, n-1),
+			payment:   principal + interest,
+			principal: principal,
+			interest:  interest,
+			escrow:    l.Escrow,
+			balance:   balance,
+		})
+	}
+	return rows
+}
+
+// summarize works out where the loan stands on the given day. When the provider reported the remaining principal, the payoff date is projected from that balance instead of the original schedule.
+func (l loan) summarize(bill *Bill, now time.Time) loanSummary {
+	today := truncateDay(now)
+	rows := l.schedule()
+	s := loanSummary{key: billKey{l.Name, l.Account}, scheduledBalance: l.Principal}
+
+	for _, row := range rows {
+		if !row.date.Before(today) {
+			s.next = row
+			break
+		}
+		s.paymentsMade++
+		s.interestToDate += row.interest
+		s.scheduledBalance = row.balance
+	}
+
+	s.balance = s.scheduledBalance
+	if len(rows) > 0 {
+		s.payoffDate = rows[len(rows)-1].date
+	}
+
+	if bill != nil && bill.retrieved {
+		s.scrapedPayment = bill.amountDue
+	}
+	if bill != nil && bill.principalBalance > 0 && s.next.number > 0 {
+		s.balance = bill.principalBalance
+
+		// Pay the scraped balance down with the scheduled payment to see when it actually hits zero
+		r := l.Rate / 100 / 12
+		payment := monthlyPayment(l.Principal, l.Rate, l.TermMonths)
+		balance := s.balance
+		for n := s.next.number - 1; balance > 0.005 && n < l.TermMonths*2; n++ {
+			balance = roundCents(balance + roundCents(balance*r) - roundCents(payment))
+			s.payoffDate = addMonthsOnDay(l.start, n)
+		}
+	}
+
+	return s
+}
+
+// paymentDifference is how far the amount the provider asked for is from the scheduled payment plus escrow
+func (s loanSummary) paymentDifference() float64 {
+	if s.scrapedPayment == 0 || s.next.number == 0 {
+		return 0
+	}
+	return roundCents(s.scrapedPayment - (s.next.payment + s.next.escrow))
+}
+
+// loanSummaries summarizes every configured loan against the bills retrieved in a run
+func loanSummaries(bills []billEntry, now time.Time) []loanSummary {
+	var summaries []loanSummary
+	for _, l := range cfg.Loans {
+		var bill *Bill
+		for _, entry := range bills {
+			if entry.key() == (billKey{l.Name, l.Account}) {
+				bill = entry.bill
+			}
+		}
+		summaries = append(summaries, l.summarize(bill, now))
+	}
+	return summaries
+}
+
+func writeLoanToInfluxDB(influx *influxBatch, s loanSummary) {
+	point := influxdb2.NewPointWithMeasurement("loan").
+		AddTag("type", s.key.Type).
+		AddField("scheduled_payment", s.next.payment).
+		AddField("principal", s.next.principal).
+		AddField("interest", s.next.interest).
+		AddField("escrow", s.next.escrow).
+		AddField("remaining_balance", s.balance).
+		AddField("scheduled_balance", s.scheduledBalance).
+		AddField("interest_to_date", s.interestToDate).
+		AddField("payment_difference", s.paymentDifference()).
+		AddField("payoff_date", s.payoffDate.UTC().Format(time.RFC3339)).
+		SetTime(time.Now())
+	if s.key.Account != "" {
+		point.AddTag("account", s.key.Account)
+	}
+
+	influx.add(point)
+}
+
+func renderLoanSummaries(summaries []loanSummary) {
+	rows := [][]string{{"Loan", "Next Payment ($)", "Principal ($)", "Interest ($)", "Escrow ($)", "Balance ($)", "Interest To Date ($)", "Payoff Date", "Difference ($)"}}
+	for _, s := range summaries {
+		rows = append(rows, []string{
+			s.key.label(),
+			fmt.Sprintf("%.2f", s.next.payment+s.next.escrow),
+			fmt.Sprintf("%.2f", s.next.principal),
+			fmt.Sprintf("%.2f", s.next.interest),
+			fmt.Sprintf("%.2f", s.next.escrow),
+			fmt.Sprintf("%.2f", s.balance),
+			fmt.Sprintf("%.2f", s.interestToDate),
+			s.payoffDate.Format("01/02/2006"),
+			fmt.Sprintf("%+.2f", s.paymentDifference()),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+}
+
+func renderLoanSchedule(l loan) {
+	rows := [][]string{{"#", "Date", "Payment ($)", "Principal ($)", "Interest ($)", "Escrow ($)", "Balance ($)"}}
+	for _, row := range l.schedule() {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", row.number),
+			row.date.Format("01/02/2006"),
+			fmt.Sprintf("%.2f", row.payment+row.escrow),
+			fmt.Sprintf("%.2f", row.principal),
+			fmt.Sprintf("%.2f", row.interest),
+			fmt.Sprintf("%.2f", row.escrow),
+			fmt.Sprintf("%.2f", row.balance),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+}
+
+// addMonthsOnDay moves t forward by n months, keeping its day of the month where the month is long enough
+func addMonthsOnDay(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, time.Local)
+	return time.Date(first.Year(), first.Month(), min(t.Day(), daysIn(first.Year(), first.Month())), 0, 0, 0, 0, time.Local)
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
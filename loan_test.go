@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func testLoan(t *testing.T) loan {
+	t.Helper()
+	l := loan{Name: "Mortgage", Principal: 240000, Rate: 6.125, TermMonths: 360, Start: "2023-08-01", Escrow: 412.18}
+	if err := l.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestMonthlyPayment(t *testing.T) {
+	tests := []struct {
+		balance float64
+		rate    float64
+		months  int
+		want    float64
+	}{
+		{240000, 6.125, 360, 1458.27},
+		{20000, 5, 60, 377.42},
+		{12000, 0, 12, 1000},
+	}
+	for _, tt := range tests {
+		if got := roundCents(monthlyPayment(tt.balance, tt.rate, tt.months)); got != tt.want {
+			t.Errorf("monthlyPayment(%v, %v, %d) = %v, want %v", tt.balance, tt.rate, tt.months, got, tt.want)
+		}
+	}
+}
+
+func TestLoanSchedule(t *testing.T) {
+	rows := testLoan(t).schedule()
+	if len(rows) != 360 {
+		t.Fatalf("schedule has %d payments, want 360", len(rows))
+	}
+
+	first := rows[0]
+	if first.interest != 1225 || roundCents(first.principal) != 233.27 || first.balance != 239766.73 || first.date.Format(dateLayout) != "2023-08-01" {
+		t.Errorf("first payment = %+v", first)
+	}
+
+	// Together the payments repay exactly what was borrowed
+	principal := 0.0
+	for _, row := range rows {
+		principal += row.principal
+	}
+	if math.Abs(principal-240000) > 0.005 {
+		t.Errorf("principal repaid = %.2f, want 240000.00", principal)
+	}
+	last := rows[len(rows)-1]
+	if last.balance != 0 || last.date.Format(dateLayout) != "2053-07-01" {
+		t.Errorf("last payment = %+v, want a zero balance on 2053-07-01", last)
+	}
+}
+
+func TestLoanSummarize(t *testing.T) {
+	l := testLoan(t)
+	now := date("2023-10-15")
+
+	s := l.summarize(nil, now)
+	if s.paymentsMade != 3 || s.next.number != 4 || s.next.date.Format(dateLayout) != "2023-11-01" {
+		t.Errorf("payments made = %d, next = #%d on %s, want 3 made and #4 on 2023-11-01", s.paymentsMade, s.next.number, s.next.date.Format(dateLayout))
+	}
+	if roundCents(s.interestToDate) != 3671.42 {
+		t.Errorf("interest to date = %.2f, want 3671.42", s.interestToDate)
+	}
+	if s.balance != 239296.61 || s.payoffDate.Format(dateLayout) != "2053-07-01" {
+		t.Errorf("balance = %.2f, payoff = %s, want 239296.61 and 2053-07-01", s.balance, s.payoffDate.Format(dateLayout))
+	}
+	if s.paymentDifference() != 0 {
+		t.Errorf("payment difference without a scraped bill = %.2f, want 0", s.paymentDifference())
+	}
+
+	// Extra principal paid down shows up in the balance the provider reports and brings the payoff forward
+	bill := &Bill{retrieved: true, amountDue: 1880.45, principalBalance: 200000}
+	s = l.summarize(bill, now)
+	if s.balance != 200000 || s.scheduledBalance != 239296.61 {
+		t.Errorf("balance = %.2f, scheduled = %.2f, want 200000.00 and 239296.61", s.balance, s.scheduledBalance)
+	}
+	if got := s.payoffDate.Format(dateLayout); got != "2043-07-01" {
+		t.Errorf("payoff = %s, want 2043-07-01", got)
+	}
+	if s.paymentDifference() != 10 {
+		t.Errorf("payment difference = %.2f, want 10.00", s.paymentDifference())
+	}
+}
+
+func TestLoanPaidOff(t *testing.T) {
+	s := testLoan(t).summarize(nil, date("2060-01-01"))
+	if s.paymentsMade != 360 || s.next.number != 0 || s.balance != 0 {
+		t.Errorf("payments made = %d, next = #%d, balance = %.2f, want 360, none and 0", s.paymentsMade, s.next.number, s.balance)
+	}
+}
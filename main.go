@@ -3,6 +3,7 @@ package main
 import (
 	"billburner/cd"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,432 +19,761 @@ import (
 	"github.com/pterm/pterm"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
-	"github.com/influxdata/influxdb-client-go/v2/api"
 )
 
 var browser context.Context
 var closeBrowser context.CancelFunc
 
 type Bill struct {
-	amountDue float64
-	dueDate   int64
-	retrieved bool
+	amountDue  float64
+	dueDate    int64
+	retrieved  bool
+	failure    string
+	screenshot string
+
+	// Set once amountDue holds a figure read from the site, so a balance that failed to parse is not taken for $0.00
+	amountParsed bool
+
+	// Remaining loan principal, when the provider shows it
+	principalBalance float64
+
+	// Payment status, when the provider shows it
+	statementBalance     float64
+	currentBalance       float64
+	lastPaymentAmount    float64
+	lastPaymentDate      int64
+	autopay              bool
+	scheduledPaymentDate int64
+
+	// Metered usage for utilities that show it
+	usage usage
+
+	// Bank transaction that paid this cycle, once one has been matched
+	paidReference string
+
+	// Problems worth a look that did not stop the bill from being retrieved
+	warnings []string
+}
+
+type billEntry struct {
+	provider  string
+	name      string
+	account   string
+	household string
+	bill      *Bill
+}
+
+// label is the name shown for the bill, which includes the account nickname when there is one
+func (e billEntry) label() string {
+	return e.key().label()
+}
+
+// fail logs a provider error and records it on the bill so it can be shown in the run report
+func (b *Bill) fail(format string, args ...any) {
+	b.failure = fmt.Sprintf(format, args...)
+	log.Printf("error: %s", b.failure)
+}
+
+// warn logs a problem that did not stop the bill from being retrieved and records it for the run report
+func (b *Bill) warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	b.warnings = append(b.warnings, msg)
+	log.Printf("warning: %s", msg)
 }
 
 func init() {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
+	// Load .env file. Without one the settings come from the environment alone, as in containers and tests.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
+
+	// Load config file
+	var err error
+	if cfg, err = loadConfig(configPath()); err != nil {
+		log.Fatalf("Error loading config file: %v", err)
+	}
 }
 
 func main() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		closeBrowser()
-		os.Exit(1)
-	}()
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	go func() {
-		time.Sleep(2 * time.Minute)
-		closeBrowser()
-		os.Exit(2)
+	// Deferred first so it runs after the other deferred cleanup
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
 	}()
 
-	client := influxdb2.NewClient(os.Getenv("INFLUXDB_URL"), os.Getenv("INFLUXDB_TOKEN"))
-	writeAPI := client.WriteAPIBlocking(os.Getenv("INFLUXDB_ORG"), os.Getenv("INFLUXDB_BUCKET"))
-	defer client.Close()
+	influx := newInfluxBatch()
+	defer influx.Close()
+	if err := influx.checkHealth(); err != nil {
+		fmt.Println("Warning:", err)
+	}
+	// The car payment used to be built in, so an old config would drop it without a word
+	if len(cfg.Recurring) == 0 {
+		fmt.Printf("Warning: %s lists no recurring bills, so fixed payments such as the car loan are left out. See config.example.json.\n", configPath())
+	}
+
+	timeout := runTimeout()
+	stopCtx, stop := context.WithDeadline(context.Background(), time.Now().Add(timeout))
+	defer stop()
+	var interrupted atomic.Bool
+	watchRun(stop, stopCtx, timeout, &interrupted, influx)
 
-	var err error
-	browser, closeBrowser, err = cd.CreateBrowser(false, true, true)
+	sink, err := dialMQTT()
+	if err != nil {
+		fmt.Println("Error connecting to MQTT broker:", err)
+	}
+	if sink != nil {
+		defer sink.Close()
+	}
+
+	root, closeRoot, err := openBrowser()
 	if err != nil {
 		fmt.Println("Error creating browser:", err)
 		return
 	}
+	closeBrowser = closeRoot
 	defer closeBrowser()
 
+	// Stopping the run cancels browser actions through this context, while screenshots still use root. The fallback selectors it uses are recorded for the bill table.
+	var cancelActions context.CancelFunc
+	browser, cancelActions = context.WithCancel(cd.RecordFallbacks(root))
+	defer cancelActions()
+	defer context.AfterFunc(stopCtx, cancelActions)()
+
 	start := time.Now()
 
-	var bills = []struct {
-		name string
-		bill *Bill
-	}{
-		{"Internet", &Bill{}},
-		{"Wireless", &Bill{}},
-		{"Water", &Bill{}},
-		{"Power", &Bill{}},
-		{"Gas", &Bill{}},
-		{"Sewer", &Bill{}},
-		{"Mortgage", &Bill{}},
-		{"Car", &Bill{}},
-		{"Insurance", &Bill{}},
-	}
-
-	// Retrieve bills in parallel
-	for _, entry := range bills {
-		switch entry.name {
-		case "Car":
-			getCarBill(entry.bill)
-		case "Insurance":
-			getInsuranceBill(entry.bill)
-		case "Power":
-			getPowerBill(entry.bill)
-		case "Gas":
-			getGasBill(entry.bill)
-		case "Wireless":
-			getPhoneBill(entry.bill, bills[0].bill)
-			writeBillToInfluxDB(writeAPI, "Internet", bills[0].bill)
-		case "Internet":
-			continue // Handled with Wireless
-		case "Sewer":
-			getSewerBill(entry.bill)
-		case "Water":
-			getWaterBill(entry.bill)
-		case "Mortgage":
-			getMortgageBill(entry.bill)
+	st, err := loadStore(storePath())
+	if err != nil {
+		fmt.Println("Error loading store:", err)
+		return
+	}
+
+	var jobs []billJob
+	for _, acct := range cfg.Accounts {
+		jobs = append(jobs, accountJob(acct))
+	}
+	for _, rb := range cfg.Recurring {
+		// Skip loans that have been paid off
+		if _, ok := rb.next(start, cfg.holidays); ok {
+			bill := &Bill{}
+			jobs = append(jobs, billJob{entries: []billEntry{{name: rb.Name, bill: bill}}, fetch: func() { getRecurringBill(bill, rb) }})
+		}
+	}
+
+	var bills []billEntry
+	for _, job := range jobs {
+		bills = append(bills, job.entries...)
+	}
+
+	// Retrieve bills
+	visited := map[string]bool{}
+	for _, job := range jobs {
+		// Once the run is stopped the remaining sites are not opened, but recurring bills need no browser
+		if job.provider != "" && stopCtx.Err() != nil {
+			for _, entry := range job.entries {
+				entry.bill.fail("not fetched, %s", stopReason(&interrupted, timeout))
+			}
+			continue
+		}
+
+		// Log out of the previous account before signing in to another one at the same provider
+		if job.provider != "" && visited[job.provider] {
+			cd.ClearCookies(browser)
+		}
+		visited[job.provider] = true
+
+		job.fetch()
+
+		// Surface fallback selectors so the broken primary can be fixed
+		for _, fb := range cd.TakeFallbacks(browser) {
+			job.entries[0].bill.warn("%s", fb)
 		}
 
 		//fmt.Println("\033[H\033[2J")
 		renderBillTable(bills)
 
-		if entry.bill.retrieved {
-			writeBillToInfluxDB(writeAPI, entry.name, entry.bill)
+		for _, entry := range job.entries {
+			if !entry.bill.retrieved {
+				entry.bill.screenshot = captureFailureScreenshot(root, entry.label(), start)
+			}
+		}
+
+		// The statement is checked before writing so it can stand in for a failed scrape
+		if job.statement != "" && stopCtx.Err() == nil {
+			downloadStatement(st, job)
 		}
+
+		for _, entry := range job.entries {
+			if m, ok := st.match(entry.key(), entry.bill.dueDate); ok && entry.bill.retrieved {
+				entry.bill.paidReference = m.Reference
+			}
+			if entry.bill.retrieved {
+				writeBillToInfluxDB(influx, entry)
+				if sink != nil {
+					if err := sink.publishBill(entry); err != nil {
+						fmt.Println("Error publishing to MQTT:", err)
+					}
+				}
+			}
+		}
+	}
+
+	for _, summary := range loanSummaries(bills, time.Now()) {
+		writeLoanToInfluxDB(influx, summary)
+	}
+
+	if err := influx.flush(); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	st.addRun(bills, time.Now())
+	if cfg.Bank != nil {
+		reconcilePayments(cfg.Bank, st)
+	}
+	exportJournals(st)
+	if cfg.Budget != nil {
+		if err := writeBudgetExports(cfg.Budget, bills); err != nil {
+			fmt.Println("Error writing budget exports:", err)
+		}
+	}
+	if err := st.save(); err != nil {
+		fmt.Println("Error saving store:", err)
+	}
+	if err := writeHTMLReport(reportDir(), bills, st, start, time.Since(start)); err != nil {
+		fmt.Println("Error writing HTML report:", err)
 	}
 
 	fmt.Println("Done :)")
 	fmt.Println("Time Elapsed: ", time.Since(start))
+
+	if stopCtx.Err() != nil {
+		fmt.Println("Run stopped early:", stopReason(&interrupted, timeout))
+		exitCode = 2
+		if interrupted.Load() {
+			exitCode = 1
+		}
+	}
+}
+
+// Time budgeted for each account, and on top of that for each statement download, when RUN_TIMEOUT is not set
+const (
+	accountBudget   = 45 * time.Second
+	statementBudget = 35 * time.Second
+	minRunTimeout   = 2 * time.Minute
+)
+
+// runTimeout is how long a run may take before its browser actions are stopped: RUN_TIMEOUT (e.g. 5m) when set, otherwise a budget for each configured account and statement download, at least minRunTimeout
+func runTimeout() time.Duration {
+	if s := os.Getenv("RUN_TIMEOUT"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err == nil && d > 0 {
+			return d
+		}
+		fmt.Println("Error parsing RUN_TIMEOUT, using the default:", s)
+	}
+
+	var budget time.Duration
+	for _, acct := range cfg.Accounts {
+		budget += accountBudget
+		if acct.Statement != "" {
+			budget += statementBudget
+		}
+	}
+	if budget < minRunTimeout {
+		return minRunTimeout
+	}
+	return budget
+}
+
+// shutdownGrace is how long a stopped run gets to write its store and report before the process is killed
+const shutdownGrace = 30 * time.Second
+
+// watchRun stops the run on an interrupt or at stopCtx's deadline. Stopping cancels the browser actions rather than the process, so the jobs that are left fail fast and the store and report are still written. Only if that takes longer than shutdownGrace, or a second interrupt arrives, are the pending Influx points spooled and the process killed.
+func watchRun(stop context.CancelFunc, stopCtx context.Context, timeout time.Duration, interrupted *atomic.Bool, influx *influxBatch) {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-c:
+			interrupted.Store(true)
+			stop()
+			fmt.Println("Interrupted, finishing the run. Interrupt again to quit at once.")
+		case <-stopCtx.Done():
+			if !errors.Is(stopCtx.Err(), context.DeadlineExceeded) {
+				return // the run finished
+			}
+			fmt.Printf("Run took longer than %s, finishing the run\n", timeout)
+		}
+
+		select {
+		case <-c:
+		case <-time.After(shutdownGrace):
+		}
+		influx.spoolPending()
+		if closeBrowser != nil {
+			closeBrowser()
+		}
+		if interrupted.Load() {
+			os.Exit(1)
+		}
+		os.Exit(2)
+	}()
+}
+
+// stopReason describes why the run was stopped, for the failures it leaves behind
+func stopReason(interrupted *atomic.Bool, timeout time.Duration) string {
+	if interrupted.Load() {
+		return "the run was interrupted"
+	}
+	return fmt.Sprintf("the run took longer than %s", timeout)
+}
+
+// openBrowser starts Chrome, or attaches to the one in CHROME_REMOTE_URL
+func openBrowser() (context.Context, context.CancelFunc, error) {
+	if remote := os.Getenv("CHROME_REMOTE_URL"); remote != "" {
+		return cd.ConnectBrowser(remote, browserOptions())
+	}
+	return cd.NewBrowser(browserOptions())
+}
+
+// browserOptions starts from the defaults BillBurner has always used and applies the CHROME_* overrides from the environment. CHROME_EVASIONS limits the stealth patches to a comma separated list, e.g. webgl,media_codecs. Setting CHROME_REMOTE_URL attaches to a running browser instead of starting one.
+func browserOptions() cd.BrowserOptions {
+	evasions, err := cd.ParseEvasions(os.Getenv("CHROME_EVASIONS"))
+	if err != nil {
+		fmt.Println("Error parsing CHROME_EVASIONS, using every evasion:", err)
+	}
+	return cd.BrowserOptions{
+		Evasions:  evasions,
+		Fresh:     true,
+		Bypass:    true,
+		Headless:  os.Getenv("CHROME_HEADLESS") == "true",
+		UserAgent: os.Getenv("CHROME_USER_AGENT"),
+		Locale:    os.Getenv("CHROME_LOCALE"),
+		Timezone:  os.Getenv("CHROME_TIMEZONE"),
+		Proxy:     os.Getenv("CHROME_PROXY"),
+		ExecPath:  os.Getenv("CHROME_PATH"),
+	}
 }
 
-func renderBillTable(bills []struct {
-	name string
-	bill *Bill
-}) {
+func renderBillTable(bills []billEntry) {
 	rows := make([][]string, len(bills)+2) // +2 to account for the header and total row
-	rows[0] = []string{"Bill Type", "Amount Due ($)", "Due Date", "Days Until Due"}
+	rows[0] = []string{"Bill Type", "Household", "Amount Due ($)", "Due Date", "Days Until Due", "Status"}
 	totalDue := 0.0 // Initialize total amount due
 
 	for i, entry := range bills {
 		dueDate := "N/A"
 		daysUntilDue := "N/A"
 		if entry.bill.dueDate != 0 {
-			dueTime := time.Unix(entry.bill.dueDate, 0)
-			dueDate = dueTime.Format("01/02/2006")
-			days := int(time.Until(dueTime).Hours() / 24)
-			if days < -100 {
-				daysUntilDue = "0"
-			} else {
-				daysUntilDue = strconv.Itoa(days)
-			}
+			dueDate = time.Unix(entry.bill.dueDate, 0).Format("01/02/2006")
+			daysUntilDue = strconv.Itoa(daysUntil(entry.bill.dueDate))
 		}
-		rows[i+1] = []string{entry.name, fmt.Sprintf("%.2f", entry.bill.amountDue), dueDate, daysUntilDue}
+		rows[i+1] = []string{entry.label(), entry.household, fmt.Sprintf("%.2f", entry.bill.amountDue), dueDate, daysUntilDue, entry.bill.status()}
 		totalDue += entry.bill.amountDue // Update the total amount due
 	}
 
 	// Add the total row
-	rows[len(bills)+1] = []string{"Total", fmt.Sprintf("%.2f", totalDue), "", ""}
+	rows[len(bills)+1] = []string{"Total", "", fmt.Sprintf("%.2f", totalDue), "", "", ""}
 
 	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
 }
 
-func getCarBill(carBill *Bill) {
-	carBill.amountDue = 422.94
-	// Due date is always the 17th of the month. Factor in the current month to get the right due date. The due date should always be the following month, unless the date is after the 17th
-	carBill.dueDate = time.Date(time.Now().Year(), time.Now().Month(), 17, 0, 0, 0, 0, time.Local).AddDate(0, 1, 0).Unix()
-	carBill.retrieved = true
+// Helper function to compute the whole days remaining until a due date. Dates far in the past are treated as due today
+func daysUntil(dueDate int64) int {
+	days := int(time.Until(time.Unix(dueDate, 0)).Hours() / 24)
+	if days < -100 {
+		return 0
+	}
+	return days
 }
 
-func getMortgageBill(mortgageBill *Bill) {
+func getRecurringBill(bill *Bill, rb recurringBill) {
+	due, ok := rb.next(truncateDay(time.Now()), cfg.holidays)
+	if !ok {
+		bill.fail("no upcoming payment for %s, schedule ended %s", rb.Name, rb.End)
+		return
+	}
+
+	bill.amountDue = rb.Amount
+	bill.amountParsed = true
+	bill.dueDate = due.Unix()
+	bill.retrieved = true
+}
+
+func getMortgageBill(mortgageBill *Bill, creds credentials) {
 	const timeout = 15000 // milliseconds
 
 	//* Navigate to login page
 	cd.Navigate(browser, "https://mypennymac.pennymac.com/account/login")
 
 	if !cd.ElementExists(browser, "#username", timeout) {
-		log.Printf("error: username input not found within %d ms", timeout)
+		mortgageBill.fail("username input not found within %d ms", timeout)
 		return
 	}
 
 	//* Enter credentials
-	cd.InputText(browser, "#username", "sinarian", false, false)
-	cd.InputText(browser, "#password", "wr&a7PTBf!fE4#A", false, false)
+	cd.InputText(browser, "#username", creds.username, false, false)
+	cd.InputText(browser, "#password", creds.password, false, false)
 
 	//* Click login button
+	sent := time.Now()
 	cd.Click(browser, "#submit-button", false)
 
 	//* Wait for the email verification
-	time.Sleep(10 * time.Second) // Consider reducing fixed sleep time or replacing it with a more dynamic wait if possible
+	if err := cd.WaitVisible(browser, "#tfaEmail", timeout); err != nil {
+		mortgageBill.fail("verification code input not shown: %v", err)
+		return
+	}
+	code, err := cd.WaitCodeFromImap(browser, sent, 60000, "hmail.digi-safe.co", os.Getenv("IMAP_USERNAME"), os.Getenv("IMAP_PASSWORD"), "Pennymac - Email Confirmation", `PM-`, "\n", false)
+	if err != nil {
+		mortgageBill.fail("verification code not received: %v", err)
+		return
+	}
 
 	//* Enter code
-	code := cd.GetCodeFromImap("hmail.digi-safe.co", os.Getenv("IMAP_USERNAME"), os.Getenv("IMAP_PASSWORD"), "Pennymac - Email Confirmation", `PM-`, "\n", false)
 	cd.InputText(browser, "#tfaEmail", code, false, true)
 
 	//* Click verify button
 	cd.Click(browser, "#login-tfa-email-verify-btn", false)
 	if !cd.ElementExists(browser, "div.r-edyy15:nth-child(1) > div:nth-child(1) > div:nth-child(1) > div:nth-child(1)", timeout) {
-		log.Printf("error: verification section not found within %d ms", timeout)
+		mortgageBill.fail("verification section not found within %d ms", timeout)
 		return
 	}
 
 	//* Get balance due
 	balanceDue := cd.GetText(browser, "div.r-edyy15:nth-child(1) > div:nth-child(1) > div:nth-child(1) > div:nth-child(1)")
-	mortgageBill.amountDue = stringToFloat(balanceDue)
+	if !mortgageBill.setAmountDue(balanceDue) {
+		return
+	}
 
 	//* Get due date
 	dueDate := cd.GetText(browser, "div.r-edyy15:nth-child(1) > div:nth-child(1) > div:nth-child(3) > div:nth-child(1)")
 	mortgageBill.dueDate = extractWaterBillDueDate(dueDate)
 
+	//* Get principal balance
+	mortgageBill.principalBalance = extractPrincipalBalance(cd.GetText(browser, "body"))
+
+	//* Payment status
+	scrapePaymentStatus(mortgageBill)
+
 	//* Mark as successfully retrieved
 	mortgageBill.retrieved = true
 }
 
-func getPhoneBill(wirelessBill *Bill, internetBill *Bill) {
+// pace pauses between AT&T sign-in steps when human input is turned off, since the site turns away input that arrives faster than a person could type it. In human input mode the typing and clicking take their own pauses.
+func pace(d time.Duration) {
+	if !cd.IsHuman(browser) {
+		cd.Pause(browser, d)
+	}
+}
+
+func getPhoneBill(wirelessBill *Bill, internetBill *Bill, creds credentials) {
 	//* Navigate to login page
 	cd.Navigate(browser, "https://www.att.com/acctmgmt/signin")
 	if !cd.ElementExists(browser, "#userID", 10000) {
-		log.Printf("error: username input not found within 10s")
+		wirelessBill.fail("username input not found within 10s")
 		return
 	}
-
-	time.Sleep(2 * time.Second)
+	pace(2 * time.Second)
 
 	//* Enter username
-	cd.InputText(browser, "#userID", os.Getenv("ATT_USERNAME"), true, true)
-	time.Sleep(1 * time.Second)
+	cd.InputText(browser, "#userID", creds.username, true, true)
+	pace(time.Second)
 
 	cd.Click(browser, "#continueFromUserLogin", false)
 	if !cd.ElementExists(browser, "#password", 10000) {
-		log.Printf("error: password input not found within 10s")
+		wirelessBill.fail("password input not found within 10s")
 		return
 	}
-	time.Sleep(1 * time.Second)
+	pace(time.Second)
 
 	//* Enter password
-	cd.InputText(browser, "#password", os.Getenv("ATT_PASSWORD"), true, true)
-
-	time.Sleep(1 * time.Second)
+	cd.InputText(browser, "#password", creds.password, true, true)
+	pace(time.Second)
 
 	//* Click signin button
 	cd.Click(browser, "#signin", false)
 
 	if !cd.ElementExists(browser, "#chooseMethodMakePaymentButton", 10000) {
-		log.Printf("error: make payment button not found within 10s")
+		wirelessBill.fail("make payment button not found within 10s")
 		return
 	}
-	time.Sleep(1 * time.Second)
+	pace(time.Second)
 
 	//* Click make payment button
 	cd.Click(browser, "#chooseMethodMakePaymentButton", false)
 	if !cd.ElementExists(browser, ".page-title", 10000) {
-		log.Printf("error: page title not found within 10s")
+		wirelessBill.fail("page title not found within 10s")
 		return
 	}
-	time.Sleep(2 * time.Second)
 
 	//* Wireless balance
-	wirelessBalance := cd.GetText(browser, ".w-100")
-	wirelessBill.amountDue = stringToFloat(wirelessBalance)
+	wirelessBalance, err := cd.WaitTextMatch(browser, ".w-100", hasDigit, 10000)
+	if err != nil {
+		wirelessBill.fail("balance due not found: %v", err)
+		return
+	}
+	if !wirelessBill.setAmountDue(wirelessBalance) {
+		return
+	}
 
 	//* Wireless due date
 	wirelessBalanceDue := cd.GetText(browser, "div.fastpay-auth-page .option_date-picker .heading-xs")
 	// Sample: Due Apr 28, 2024
 	wirelessBill.dueDate = extractWirelessBillDueDate(wirelessBalanceDue)
 
+	//* Wireless payment status
+	scrapePaymentStatus(wirelessBill)
+	wirelessBill.retrieved = true
+
 	//* Click on internet tab
 	cd.Click(browser, "div.jsx-2552546055:nth-child(1) > div:nth-child(1) > div:nth-child(3) > div:nth-child(1)", true)
 
-	time.Sleep(2 * time.Second)
-
 	//* Internet balance
-	internetBalance := cd.GetText(browser, ".w-100")
-	internetBill.amountDue = stringToFloat(internetBalance)
+	// The tab fetches its balance after the click and shows it in place of the wireless one
+	internetBalance, err := cd.WaitTextChange(browser, ".w-100", wirelessBalance, 10000)
+	if err != nil {
+		internetBill.fail("internet balance not shown: %v", err)
+		return
+	}
+	if !internetBill.setAmountDue(internetBalance) {
+		return
+	}
 
 	//* Internet due date
 	internetBalanceDue := cd.GetText(browser, "div.jsx-3631953385:nth-child(3)")
 	// Sample: Due Apr 28, 2024
 	internetBill.dueDate = extractInternetBillDueDate(internetBalanceDue)
+
+	//* Internet payment status
+	scrapePaymentStatus(internetBill)
+
 	internetBill.retrieved = true
-	wirelessBill.retrieved = true
 }
 
-func getInsuranceBill(insuranceBill *Bill) {
+func getInsuranceBill(insuranceBill *Bill, creds credentials) {
 	//* Navigate to login page
 	cd.Navigate(browser, "https://proofing.statefarm.com/login-ui/login")
 	if !cd.ElementExists(browser, "#username", 10000) {
-		log.Printf("error: username input not found within 10s")
+		insuranceBill.fail("username input not found within 10s")
 		return
 	}
 
 	//* Enter credentials
-	cd.InputText(browser, "#username", os.Getenv("STATE_FARM_USERNAME"), false, false)
-	cd.InputText(browser, "#password", os.Getenv("STATE_FARM_PASSWORD"), false, false)
+	cd.InputText(browser, "#username", creds.username, false, false)
+	cd.InputText(browser, "#password", creds.password, false, false)
 
 	//* Click login button
 	cd.Click(browser, "#submitButton", true)
 	if !cd.ElementExists(browser, "#emailAddress > label:nth-child(2)", 10000) {
-		log.Printf("error: email verification not found within 10s")
+		insuranceBill.fail("email verification not found within 10s")
 		return
 	}
 
 	//* Click email verification
 	cd.Click(browser, "#emailAddress > label:nth-child(2)", true)
+	sent := time.Now()
 	cd.Click(browser, "#submitButton", true)
 
-	time.Sleep(10 * time.Second)
+	//* Wait for the email verification
+	if err := cd.WaitVisible(browser, "#verification_code", 10000); err != nil {
+		insuranceBill.fail("verification code input not shown: %v", err)
+		return
+	}
+	code, err := cd.WaitCodeFromImap(browser, sent, 60000, "hmail.digi-safe.co", os.Getenv("IMAP_USERNAME"), os.Getenv("IMAP_PASSWORD"), "Verification Code", `<span style=3D"color:#E22925;">`, "</", false)
+	if err != nil {
+		insuranceBill.fail("verification code not received: %v", err)
+		return
+	}
 
 	//* Enter code
-	code := cd.GetCodeFromImap("hmail.digi-safe.co", os.Getenv("IMAP_USERNAME"), os.Getenv("IMAP_PASSWORD"), "Verification Code", `<span style=3D"color:#E22925;">`, "</", false)
-
 	cd.InputText(browser, "#verification_code", code, false, false)
 	cd.Click(browser, "#submitButton", true)
 	if !cd.ElementExists(browser, ".bill-due-amt-txt", 10000) {
-		log.Printf("error: balance due not found within 10s")
+		insuranceBill.fail("balance due not found within 10s")
 		return
 	}
 
 	//* Balance due
 	balanceDue := cd.GetText(browser, ".bill-due-amt-txt")
-	insuranceBill.amountDue = stringToFloat(balanceDue)
+	if !insuranceBill.setAmountDue(balanceDue) {
+		return
+	}
 
 	//* Due date
 	dueDate := cd.GetText(browser, ".bill-due-date")
 	// Sample: May 17
 	insuranceBill.dueDate = extractInsuranceBillDueDate(dueDate)
+
+	//* Payment status
+	scrapePaymentStatus(insuranceBill)
 	insuranceBill.retrieved = true
 }
 
-func getGasBill(gasBill *Bill) {
+func getGasBill(gasBill *Bill, creds credentials) {
 	//* Navigate to login page
 	cd.Navigate(browser, "https://myaccount.spireenergy.com/web/customer/registration/#/sign-in")
 	if !cd.ElementExists(browser, "#loginEmail", 10000) {
-		log.Printf("error: username input not found within 10s")
+		gasBill.fail("username input not found within 10s")
 		return
 	}
 
 	//* Enter credentials
-	cd.InputText(browser, "#loginEmail", os.Getenv("SPIRE_USERNAME"), false, false)
-	cd.InputText(browser, "#loginPassword", os.Getenv("SPIRE_PASSWORD"), false, false)
+	cd.InputText(browser, "#loginEmail", creds.username, false, false)
+	cd.InputText(browser, "#loginPassword", creds.password, false, false)
 
 	//* Click login button
-	cd.Click(browser, "section.buttons:nth-child(4) > button:nth-child(1)", false)
-	if !cd.ElementExists(browser, ".amount-due", 10000) {
-		log.Printf("error: balance due not found within 10s")
+	cd.Click(browser, cd.Any("section.buttons:nth-child(4) > button:nth-child(1)", cd.Role("button", "Sign In")), false)
+	//* Balance due
+	balanceDue, err := cd.WaitTextMatch(browser, ".amount-due", hasDigit, 10000)
+	if err != nil {
+		gasBill.fail("balance due not found: %v", err)
+		return
+	}
+	if !gasBill.setAmountDue(balanceDue) {
 		return
 	}
-
-	time.Sleep(1 * time.Second)
-
-	//* Balance due
-	balanceDue := cd.GetText(browser, ".amount-due")
-	gasBill.amountDue = stringToFloat(balanceDue)
 
 	//* Due date
-	balanceDue = cd.GetText(browser, ".due-date")
+	balanceDue, err = cd.WaitTextMatch(browser, ".due-date", hasDigit, 10000)
+	if err != nil {
+		gasBill.warn("due date not found: %v", err)
+	}
 	// Sample: May 08, 2024
 	gasBill.dueDate = extractGasBillDueDate(balanceDue)
+
+	//* Payment status
+	scrapePaymentStatus(gasBill)
+
+	//* Usage
+	// No usage panel is known on this dashboard, so usage is read from the whole page like the payment status
+	scrapeUsage(gasBill, "body", unitTherms)
 	gasBill.retrieved = true
 }
 
-func getSewerBill(sewerBill *Bill) {
+func getSewerBill(sewerBill *Bill, creds credentials) {
 	//* Navigate to login page
 	cd.Navigate(browser, "https://myaccount.stlmsd.com/MSDSSP/Index.aspx")
 	if !cd.ElementExists(browser, "#body_content_txtUsername", 10000) {
-		log.Printf("error: username input not found within 10s")
+		sewerBill.fail("username input not found within 10s")
 		return
 	}
 
 	//* Enter credentials
-	cd.InputText(browser, "#body_content_txtUsername", os.Getenv("STLMSD_USERNAME"), true, false)
-	cd.InputText(browser, "#body_content_txtPassword", os.Getenv("STLMSD_PASSWORD"), true, false)
+	cd.InputText(browser, "#body_content_txtUsername", creds.username, true, false)
+	cd.InputText(browser, "#body_content_txtPassword", creds.password, true, false)
 
 	//* Click login button
 	cd.Click(browser, "#body_content_btnLogin", false)
 	if !cd.ElementExists(browser, "#body_content_AccountSummaryTabControl_BillingSummaryControl1_lblCurrentBalanceText", 10000) {
-		log.Printf("error: balance due not found within 10s")
+		sewerBill.fail("balance due not found within 10s")
 		return
 	}
 
 	//* Balance due
 	balanceDue := cd.GetText(browser, "#body_content_AccountSummaryTabControl_BillingSummaryControl1_lblCurrentBalanceText")
-	sewerBill.amountDue = stringToFloat(balanceDue)
+	if !sewerBill.setAmountDue(balanceDue) {
+		return
+	}
 
 	//* Due date
 	balanceDue = cd.GetText(browser, "#body_content_AccountSummaryTabControl_BillingSummaryControl1_lblAppOrLatePaymentDateText")
 	// Sample: May 6, 2024
 	sewerBill.dueDate = extractSewerBillDueDate(balanceDue)
+
+	//* Payment status
+	scrapePaymentStatus(sewerBill)
+	sewerBill.currentBalance = sewerBill.amountDue
 	sewerBill.retrieved = true
 }
 
-func getPowerBill(powerBill *Bill) {
+func getPowerBill(powerBill *Bill, creds credentials) {
 	//* Navigate to the login page
 	cd.Navigate(browser, "https://www.ameren.com/login-page/")
 	if !cd.ElementExists(browser, "#txtSignInEmail", 10000) {
-		log.Printf("error: email input not found within 10s")
+		powerBill.fail("email input not found within 10s")
 		return
 	}
 
 	//* Enter credentials
-	cd.InputText(browser, "#txtSignInEmail", os.Getenv("AMEREN_USERNAME"), false, false)
-	cd.InputText(browser, ".input-password > input:nth-child(1)", os.Getenv("AMEREN_PASSWORD"), false, false)
+	cd.InputText(browser, "#txtSignInEmail", creds.username, false, false)
+	cd.InputText(browser, ".input-password > input:nth-child(1)", creds.password, false, false)
 
 	//* Click the login button
-	cd.Click(browser, "#btnLogin", false)
-	if !cd.ElementExists(browser, ".amount", 10000) {
-		log.Printf("error: balance due not found within 10s")
+	cd.Click(browser, cd.Any("#btnLogin", cd.Role("button", "Sign In")), false)
+	//* Balance due
+	amountDue, err := cd.WaitTextMatch(browser, ".amount", hasDigit, 10000)
+	if err != nil {
+		powerBill.fail("balance due not found: %v", err)
 		return
 	}
 
-	time.Sleep(2 * time.Second)
-
-	//* Balance due
-	amountDue := cd.GetText(browser, ".amount")
-
 	//* Due date
-	dueDate := cd.GetText(browser, ".alert")
+	dueDate, err := cd.WaitTextMatch(browser, ".alert", hasDigit, 10000)
+	if err != nil {
+		powerBill.warn("due date not found: %v", err)
+	}
 
-	powerBill.amountDue = stringToFloat(amountDue)
+	if !powerBill.setAmountDue(amountDue) {
+		return
+	}
 	powerBill.dueDate = extractPowerBillDueDate(dueDate)
+
+	//* Payment status
+	scrapePaymentStatus(powerBill)
+
+	//* Usage
+	// No usage panel is known on this dashboard, so usage is read from the whole page like the payment status
+	scrapeUsage(powerBill, "body", unitKWh)
 	powerBill.retrieved = true
 }
 
-func getWaterBill(waterBill *Bill) {
+func getWaterBill(waterBill *Bill, creds credentials) {
 	//* Navigate to login page
 	cd.Navigate(browser, "https://stlo-egov.aspgov.com/Click2GovCX/index.html")
 	if !cd.ElementExists(browser, ".lastTopRowMenuItem > a:nth-child(1)", 10000) {
-		log.Printf("error: login button not found within 10s")
+		waterBill.fail("login button not found within 10s")
 		return
 	}
 
 	//* Click login button
 	cd.Click(browser, ".lastTopRowMenuItem > a:nth-child(1)", false)
 	if !cd.ElementExists(browser, "#email\\.emailId", 10000) {
-		log.Printf("error: username input not found within 10s")
+		waterBill.fail("username input not found within 10s")
 		return
 	}
 
 	//* Enter credentials
-	cd.InputText(browser, "#email\\.emailId", os.Getenv("STLO_EGOV_USERNAME"), true, false)
-	cd.InputText(browser, "#password", os.Getenv("STLO_EGOV_PASSWORD"), true, false)
+	cd.InputText(browser, "#email\\.emailId", creds.username, true, false)
+	cd.InputText(browser, "#password", creds.password, true, false)
 
 	//* Click logon button
 	cd.Click(browser, "#submitButton", false)
 	if !cd.ElementExists(browser, ".menuWrapper > ul:nth-child(1) > li:nth-child(6) > a:nth-child(1)", 10000) {
-		log.Printf("error: account info button not found within 10s")
+		waterBill.fail("account info button not found within 10s")
 		return
 	}
 
 	//* Click account info
 	cd.Click(browser, ".menuWrapper > ul:nth-child(1) > li:nth-child(6) > a:nth-child(1)", false)
 	if !cd.ElementExists(browser, ".menuWrapper > ul:nth-child(1) > li:nth-child(6) > a:nth-child(1)", 10000) {
-		log.Printf("error: balance due not found within 10s")
+		waterBill.fail("balance due not found within 10s")
 		return
 	}
 
 	//* Get balance due
 	balanceDue := cd.GetText(browser, ".menuWrapper > ul:nth-child(1) > li:nth-child(6) > a:nth-child(1)")
-	waterBill.amountDue = stringToFloat(balanceDue)
+	if !waterBill.setAmountDue(balanceDue) {
+		return
+	}
 
 	//* Get due date
 	dueDate := cd.GetText(browser, "#contentPanel > p:nth-child(9)")
@@ -450,26 +781,41 @@ func getWaterBill(waterBill *Bill) {
 	dueDate = strings.Split(dueDate, ".")[0]
 	// Sample: 04/23/2024
 	waterBill.dueDate = extractWaterBillDueDate(dueDate)
+
+	//* Payment status
+	scrapePaymentStatus(waterBill)
+
+	//* Usage
+	scrapeUsage(waterBill, "#contentPanel", unitGallons)
 	waterBill.retrieved = true
 }
 
+// hasDigit tells a filled-in amount from an empty placeholder
+var hasDigit = regexp.MustCompile(`\d`)
+
 // Helper function to convert a string to a float like we are doing in the rest of the code with regex
 func stringToFloat(value string) float64 {
-	re := regexp.MustCompile(`\$\s*([0-9]+\.[0-9]+)`)
+	balance, _ := parseAmount(value)
+	return balance
+}
+
+// parseAmount reads the first dollar amount in a string, reporting whether there was one
+func parseAmount(value string) (float64, bool) {
+	re := regexp.MustCompile(`\$\s*([0-9,]+\.[0-9]+)`)
 	match := re.FindStringSubmatch(value)
 
 	if len(match) > 1 {
-		numberStr := match[1]
+		numberStr := strings.ReplaceAll(match[1], ",", "")
 
 		// Convert string to float
 		balance, err := strconv.ParseFloat(numberStr, 64)
 		if err != nil {
-			return 0
+			return 0, false
 		}
 
-		return balance
+		return balance, true
 	} else {
-		return 0
+		return 0, false
 	}
 }
 
@@ -499,6 +845,15 @@ func extractPowerBillDueDate(input string) int64 {
 	return parseDate(dateStr, format)
 }
 
+func extractPrincipalBalance(input string) float64 {
+	re := regexp.MustCompile(`(?i)principal balance[^$]*(\$\s*[0-9,]+\.[0-9]{2})`)
+	match := re.FindStringSubmatch(input)
+	if len(match) < 2 {
+		return 0
+	}
+	return stringToFloat(match[1])
+}
+
 func extractGasBillDueDate(input string) int64 {
 	format := "Jan 02, 2006"
 	return parseDate(input, format)
@@ -541,20 +896,44 @@ func extractWaterBillDueDate(input string) int64 {
 	return parseDate(input, format)
 }
 
-func writeBillToInfluxDB(writeAPI api.WriteAPIBlocking, billName string, bill *Bill) {
-	daysUntilDue := int(time.Until(time.Unix(bill.dueDate, 0)).Hours() / 24)
-	if daysUntilDue < -100 {
-		daysUntilDue = 0
-	}
-
+func writeBillToInfluxDB(influx *influxBatch, entry billEntry) {
+	bill := entry.bill
 	point := influxdb2.NewPointWithMeasurement("bill").
-		AddTag("type", billName).
+		AddTag("type", entry.name).
 		AddField("amount_due", bill.amountDue).
 		AddField("due_date", time.Unix(bill.dueDate, 0).UTC().Format(time.RFC3339)). // Format as ISO 8601
-		AddField("days_until_due", daysUntilDue).
+		AddField("days_until_due", daysUntil(bill.dueDate)).
+		AddField("status", bill.status()).
+		AddField("statement_balance", bill.statementBalance).
+		AddField("current_balance", bill.currentBalance).
+		AddField("last_payment_amount", bill.lastPaymentAmount).
+		AddField("autopay", bill.autopay).
 		SetTime(time.Now())
-
-	if err := writeAPI.WritePoint(context.Background(), point); err != nil {
-		fmt.Printf("Error writing point to InfluxDB: %s\n", err)
+	if bill.lastPaymentDate != 0 {
+		point.AddField("last_payment_date", time.Unix(bill.lastPaymentDate, 0).UTC().Format(time.RFC3339))
+	}
+	if bill.scheduledPaymentDate != 0 {
+		point.AddField("scheduled_payment_date", time.Unix(bill.scheduledPaymentDate, 0).UTC().Format(time.RFC3339))
 	}
+	if bill.paidReference != "" {
+		point.AddField("paid_reference", bill.paidReference)
+	}
+	if bill.usage.unit != "" {
+		point.AddField("usage", bill.usage.value).
+			AddField("usage_unit", bill.usage.unit).
+			AddField("unit_rate", bill.unitRate())
+		if bill.usage.periodStart != 0 && bill.usage.periodEnd != 0 {
+			point.AddField("usage_period_start", time.Unix(bill.usage.periodStart, 0).UTC().Format(time.RFC3339)).
+				AddField("usage_period_end", time.Unix(bill.usage.periodEnd, 0).UTC().Format(time.RFC3339)).
+				AddField("usage_per_day", bill.usage.perDay())
+		}
+	}
+	if entry.account != "" {
+		point.AddTag("account", entry.account)
+	}
+	if entry.household != "" {
+		point.AddTag("household", entry.household)
+	}
+
+	influx.add(point)
 }
@@ -0,0 +1,378 @@
+// Package mqtt is a small MQTT 3.1.1 publisher built on the standard library. It connects, publishes at QoS 1 and waits for each acknowledgement, which is all a once-per-run exporter needs. A minimal broker is included so publishing can be tried without a real one.
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Control packet types
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// Options describes how to reach and log in to a broker
+type Options struct {
+	Addr      string // host:port
+	TLS       bool
+	ClientID  string
+	Username  string
+	Password  string        // ignored without a Username, as MQTT 3.1.1 requires
+	KeepAlive time.Duration // 60s by default
+	Timeout   time.Duration // for dialing and each acknowledgement, 10s by default
+}
+
+// Client is a connection to a broker. It is safe for use by one goroutine at a time.
+type Client struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	timeout  time.Duration
+	packetID uint16
+}
+
+// Dial connects and logs in to the broker.
+//
+// Returns an error if the broker cannot be reached or refuses the connection.
+func Dial(opts Options) (*Client, error) {
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	var conn net.Conn
+	var err error
+	if opts.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", opts.Addr, &tls.Config{})
+	} else {
+		conn, err = dialer.Dial("tcp", opts.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %v", opts.Addr, err)
+	}
+
+	c := &Client{conn: conn, r: bufio.NewReader(conn), timeout: opts.Timeout}
+
+	// Variable header: protocol name, level 4 (3.1.1), flags and keep alive
+	var flags byte = 0x02 // clean session
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4)
+	// A password may only be sent along with a user name (3.1.2.9), brokers drop the connection otherwise
+	sendPassword := opts.Username != "" && opts.Password != ""
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+	if sendPassword {
+		flags |= 0x40
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.KeepAlive/time.Second))
+
+	body = appendString(body, opts.ClientID)
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+	}
+	if sendPassword {
+		body = appendString(body, opts.Password)
+	}
+
+	if err := c.write(packetConnect<<4, body); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	kind, payload, err := c.read()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if kind != packetConnack || len(payload) < 2 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected reply to connect: packet type %d", kind)
+	}
+	if code := payload[1]; code != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused connection: %s", connackReason(code))
+	}
+	return c, nil
+}
+
+// Publish sends a message at QoS 1 and waits for the broker to acknowledge it. Retained messages are kept by the broker and delivered to anyone who subscribes later.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	c.packetID++
+	if c.packetID == 0 {
+		c.packetID = 1
+	}
+
+	var header byte = packetPublish<<4 | 1<<1 // QoS 1
+	if retain {
+		header |= 1
+	}
+	body := appendString(nil, topic)
+	body = binary.BigEndian.AppendUint16(body, c.packetID)
+	body = append(body, payload...)
+	if err := c.write(header, body); err != nil {
+		return err
+	}
+
+	for {
+		kind, reply, err := c.read()
+		if err != nil {
+			return err
+		}
+		if kind == packetPuback && len(reply) >= 2 && binary.BigEndian.Uint16(reply) == c.packetID {
+			return nil
+		}
+	}
+}
+
+// Close disconnects cleanly from the broker
+func (c *Client) Close() error {
+	c.write(packetDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+func (c *Client) write(header byte, body []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(encodePacket(header, body)); err != nil {
+		return fmt.Errorf("error writing to broker: %v", err)
+	}
+	return nil
+}
+
+func (c *Client) read() (byte, []byte, error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	header, body, err := readPacket(c.r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error reading from broker: %v", err)
+	}
+	return header >> 4, body, nil
+}
+
+func encodePacket(header byte, body []byte) []byte {
+	out := []byte{header}
+	// Remaining length is a base-128 varint
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, bool) {
+	if len(b) < 2 {
+		return "", nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, false
+	}
+	return string(b[2 : 2+n]), b[2+n:], true
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad username or password"
+	case 5:
+		return "not authorized"
+	default:
+		return fmt.Sprintf("return code %d", code)
+	}
+}
+
+// Broker is a minimal in-process broker that accepts connections and publishes and keeps retained messages. It does not deliver to subscribers; it exists to check what a publisher sends.
+type Broker struct {
+	listener net.Listener
+	mu       sync.Mutex
+	retained map[string][]byte
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewBroker starts listening on addr. Use "127.0.0.1:0" to pick a free port.
+func NewBroker(addr string) (*Broker, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error starting broker: %v", err)
+	}
+	b := &Broker{listener: l, retained: map[string][]byte{}, conns: map[net.Conn]struct{}{}}
+	b.wg.Add(1)
+	go b.serve()
+	return b, nil
+}
+
+// Addr is the address the broker listens on
+func (b *Broker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Retained returns a copy of the retained messages by topic
+func (b *Broker) Retained() map[string][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string][]byte, len(b.retained))
+	for topic, payload := range b.retained {
+		out[topic] = payload
+	}
+	return out
+}
+
+// Close stops the broker, closes the connections clients left open and waits for them to finish
+func (b *Broker) Close() error {
+	err := b.listener.Close()
+	b.mu.Lock()
+	b.closed = true
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+	return err
+}
+
+func (b *Broker) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			conn.Close()
+			return
+		}
+		b.conns[conn] = struct{}{}
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			defer func() {
+				b.mu.Lock()
+				delete(b.conns, conn)
+				b.mu.Unlock()
+				conn.Close()
+			}()
+			b.handle(conn)
+		}()
+	}
+}
+
+func (b *Broker) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Minute))
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case packetConnect:
+			// Like real brokers, refuse a password sent without a user name by dropping the connection
+			_, rest, ok := readString(body)
+			if !ok || len(rest) < 2 {
+				return
+			}
+			if flags := rest[1]; flags&0x40 != 0 && flags&0x80 == 0 {
+				return
+			}
+			conn.Write(encodePacket(packetConnack<<4, []byte{0, 0}))
+		case packetPublish:
+			topic, rest, ok := readString(body)
+			if !ok {
+				return
+			}
+			qos := header >> 1 & 0x03
+			var id []byte
+			if qos > 0 {
+				if len(rest) < 2 {
+					return
+				}
+				id, rest = rest[:2], rest[2:]
+			}
+			if header&1 == 1 {
+				b.mu.Lock()
+				if len(rest) == 0 {
+					delete(b.retained, topic)
+				} else {
+					b.retained[topic] = append([]byte(nil), rest...)
+				}
+				b.mu.Unlock()
+			}
+			if qos > 0 {
+				conn.Write(encodePacket(packetPuback<<4, id))
+			}
+		case packetPingreq:
+			conn.Write(encodePacket(packetPingresp<<4, nil))
+		case packetDisconnect:
+			return
+		}
+	}
+}
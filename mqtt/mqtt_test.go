@@ -0,0 +1,111 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+)
+
+func startBroker(t *testing.T) *Broker {
+	t.Helper()
+	b, err := NewBroker("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestPublishRetained(t *testing.T) {
+	b := startBroker(t)
+	c, err := Dial(Options{Addr: b.Addr(), ClientID: "test", Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	publish := []struct {
+		topic   string
+		payload string
+		retain  bool
+	}{
+		{"bills/power/amount", "120.50", true},
+		{"bills/gas/amount", "40.00", true},
+		{"bills/power/event", "fetched", false},
+		{"bills/gas/amount", "", true}, // an empty retained message clears the topic
+	}
+	for _, p := range publish {
+		if err := c.Publish(p.topic, []byte(p.payload), p.retain); err != nil {
+			t.Fatalf("Publish(%q): %v", p.topic, err)
+		}
+	}
+
+	got := b.Retained()
+	if len(got) != 1 || string(got["bills/power/amount"]) != "120.50" {
+		t.Errorf("Retained() = %q, want only bills/power/amount = 120.50", got)
+	}
+}
+
+func TestDialCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"anonymous", "", ""},
+		{"username only", "bills", ""},
+		{"username and password", "bills", "secret"},
+		// The broker drops a connect that carries a password flag without a user name
+		{"password only", "", "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := startBroker(t)
+			c, err := Dial(Options{Addr: b.Addr(), ClientID: "test", Username: tt.username, Password: tt.password, Timeout: 2 * time.Second})
+			if err != nil {
+				t.Fatalf("Dial: %v", err)
+			}
+			defer c.Close()
+			if err := c.Publish("t", []byte("x"), true); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+		})
+	}
+}
+
+func TestBrokerCloseWithOpenClient(t *testing.T) {
+	b, err := NewBroker("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Dial(Options{Addr: b.Addr(), ClientID: "test", Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	done := make(chan struct{})
+	go func() {
+		b.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return while a client was still connected")
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 127, 128, 16383, 16384, 300000} {
+		body := bytes.Repeat([]byte{'a'}, n)
+		header, got, err := readPacket(bufio.NewReader(bytes.NewReader(encodePacket(packetPublish<<4, body))))
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		if header != packetPublish<<4 || !bytes.Equal(got, body) {
+			t.Errorf("length %d: got header %#x and %d bytes", n, header, len(got))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"billburner/cd"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Payment states shown in the table and written to the sinks
+const (
+	statusPaid      = "paid"
+	statusScheduled = "scheduled"
+	statusUnpaid    = "unpaid"
+	statusUnknown   = "unknown"
+)
+
+var (
+	statementBalanceRe = regexp.MustCompile(`(?i)statement balance[^$]{0,60}(\$\s*[0-9,]+\.[0-9]{2})`)
+	currentBalanceRe   = regexp.MustCompile(`(?i)(?:current|account) balance[^$]{0,60}(\$\s*[0-9,]+\.[0-9]{2})`)
+	lastPaymentRe      = regexp.MustCompile(`(?i)(?:last|most recent) payment[^\n$]{0,60}(\$\s*[0-9,]+\.[0-9]{2})`)
+	lastPaymentDateRe  = regexp.MustCompile(`(?i)(?:last|most recent) payment[^\n]{0,80}?(?:on|received|date:?)\s+([A-Z][a-z]{2,8}\.? \d{1,2},? \d{4}|\d{1,2}/\d{1,2}/\d{2,4})`)
+	scheduledRe        = regexp.MustCompile(`(?i)(?:scheduled|pending) payment[^\n]{0,80}?(?:on|for|date:?)\s+([A-Z][a-z]{2,8}\.? \d{1,2},? \d{4}|\d{1,2}/\d{1,2}/\d{2,4})`)
+	autopayOnRe        = regexp.MustCompile(`(?i)auto\s*-?\s*pay(?:ment)?\s*(?:is\s*)?(?:on|enrolled|active|scheduled|set up)\b`)
+	autopayOffRe       = regexp.MustCompile(`(?i)(?:enroll in|sign up for|set up|turn on)\s+auto\s*-?\s*pay`)
+)
+
+// Date layouts seen in provider payment histories
+var paymentDateLayouts = []string{"Jan 2, 2006", "Jan 2 2006", "January 2, 2006", "01/02/2006", "1/2/2006", "01/02/06", "1/2/06"}
+
+// status works out whether a bill still needs to be paid
+func (b *Bill) status() string {
+	if !b.retrieved {
+		return statusUnknown
+	}
+	if b.paidReference != "" {
+		return statusPaid
+	}
+	// A zero that was never read from the site is a broken scrape, not a settled bill
+	if !b.amountParsed {
+		return statusUnknown
+	}
+	if b.amountDue <= 0 {
+		return statusPaid
+	}
+
+	// A payment at least as large as the statement made during this cycle settles it
+	owed := b.statementBalance
+	if owed == 0 {
+		owed = b.amountDue
+	}
+	if b.lastPaymentDate != 0 && b.dueDate != 0 && b.lastPaymentAmount >= owed &&
+		b.lastPaymentDate > time.Unix(b.dueDate, 0).AddDate(0, -1, 0).Unix() {
+		return statusPaid
+	}
+
+	if b.scheduledPaymentDate != 0 || b.autopay {
+		return statusScheduled
+	}
+	return statusUnpaid
+}
+
+// setAmountDue records the balance shown on the site. It fails the bill and returns false when the text holds no dollar amount, e.g. when the element was still a placeholder.
+func (b *Bill) setAmountDue(text string) bool {
+	amount, ok := parseAmount(text)
+	if !ok {
+		b.fail("balance due %q is not an amount", text)
+		return false
+	}
+	b.amountDue = amount
+	b.amountParsed = true
+	return true
+}
+
+// scrapePaymentStatus reads balances, the last payment, autopay enrollment and any scheduled payment from the text of the current page. Providers call it once the account dashboard is showing; anything a site does not show is left empty.
+func scrapePaymentStatus(bill *Bill) {
+	text := cd.GetText(browser, "body")
+	parsePaymentStatus(bill, text)
+}
+
+func parsePaymentStatus(bill *Bill, text string) {
+	if m := statementBalanceRe.FindStringSubmatch(text); m != nil {
+		bill.statementBalance = stringToFloat(m[1])
+	}
+	if m := currentBalanceRe.FindStringSubmatch(text); m != nil {
+		bill.currentBalance = stringToFloat(m[1])
+	}
+	if m := lastPaymentRe.FindStringSubmatch(text); m != nil {
+		bill.lastPaymentAmount = stringToFloat(m[1])
+	}
+	if m := lastPaymentDateRe.FindStringSubmatch(text); m != nil {
+		bill.lastPaymentDate = parsePaymentDate(m[1])
+	}
+	if m := scheduledRe.FindStringSubmatch(text); m != nil {
+		bill.scheduledPaymentDate = parsePaymentDate(m[1])
+	}
+	bill.autopay = autopayOnRe.MatchString(text) && !autopayOffRe.MatchString(text)
+}
+
+// parsePaymentDate reads a date as written, unlike parseDate which moves due dates a day later
+func parsePaymentDate(input string) int64 {
+	input = strings.TrimSpace(strings.Replace(input, ".", "", 1))
+	for _, layout := range paymentDateLayouts {
+		if t, err := time.Parse(layout, input); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
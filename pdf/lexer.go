@@ -0,0 +1,190 @@
+package pdf
+
+import (
+	"strconv"
+)
+
+type tokenKind int
+
+const (
+	tokNumber tokenKind = iota
+	tokString
+	tokName
+	tokArrayStart
+	tokArrayEnd
+	tokOperator
+	tokOther
+)
+
+type token struct {
+	kind tokenKind
+	num  float64
+	str  []byte // decoded bytes of a string operand
+	op   string // operator or name without the slash
+}
+
+// lexer splits a content stream into operands and operators
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (l *lexer) next() (token, bool) {
+	for {
+		for l.pos < len(l.data) && isSpace(l.data[l.pos]) {
+			l.pos++
+		}
+		if l.pos >= len(l.data) {
+			return token{}, false
+		}
+		if l.data[l.pos] != '%' {
+			break
+		}
+		for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+			l.pos++
+		}
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokString, str: l.literal()}, true
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return token{kind: tokOther, op: "<<"}, true
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return token{kind: tokOther, op: ">>"}, true
+	case c == '<':
+		l.pos++
+		return token{kind: tokString, str: l.hex()}, true
+	case c == '[':
+		l.pos++
+		return token{kind: tokArrayStart}, true
+	case c == ']':
+		l.pos++
+		return token{kind: tokArrayEnd}, true
+	case c == '/':
+		l.pos++
+		return token{kind: tokName, op: l.word()}, true
+	case isDelim(c):
+		l.pos++
+		return token{kind: tokOther, op: string(c)}, true
+	}
+
+	w := l.word()
+	if n, err := strconv.ParseFloat(w, 64); err == nil {
+		return token{kind: tokNumber, num: n}, true
+	}
+	// Inline images carry binary data that would confuse the lexer, skip to their end
+	if w == "ID" {
+		l.skipInlineImage()
+		return token{kind: tokOther, op: "EI"}, true
+	}
+	return token{kind: tokOperator, op: w}, true
+}
+
+func (l *lexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start {
+		// Never stall on a stray byte
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// literal reads a (string) with nested parentheses and escapes, after the opening parenthesis
+func (l *lexer) literal() []byte {
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hex reads a <hex string> after the opening bracket
+func (l *lexer) hex() []byte {
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	return hexBytes(string(digits))
+}
+
+func (l *lexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if l.data[l.pos] == 'E' && l.data[l.pos+1] == 'I' && isSpace(l.data[l.pos-1]) && (l.pos+2 == len(l.data) || isSpace(l.data[l.pos+2])) {
+			l.pos += 2
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
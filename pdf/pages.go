@@ -0,0 +1,200 @@
+package pdf
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	catalogRe = regexp.MustCompile(`/Type\s*/Catalog\b`)
+	pageRe    = regexp.MustCompile(`/Type\s*/Page\b`)
+	formRe    = regexp.MustCompile(`/Subtype\s*/Form\b`)
+	refRe     = regexp.MustCompile(`^(\d+)\s+\d+\s+R\b`)
+	refsRe    = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+)
+
+// maxDepth bounds the page tree and nested forms, so a malformed file cannot loop forever
+const maxDepth = 32
+
+// document is a parsed file along with the font maps read from it so far
+type document struct {
+	objects map[string]object
+	cmaps   map[string]*cmap // by font object number, nil when the font has no ToUnicode map
+}
+
+// page is a leaf of the page tree with the resources it uses, inherited ones included
+type page struct {
+	dict      string
+	resources resources
+}
+
+// resources are what a content stream can refer to by name
+type resources struct {
+	fonts map[string]*cmap
+	forms map[string]string // form XObject names to their object number
+}
+
+// pages returns the pages in reading order by walking the page tree from the catalog. It returns nil when the file has no usable page tree.
+func (d *document) pages() []page {
+	for _, id := range sortedIDs(d.objects) {
+		if !catalogRe.MatchString(d.objects[id].dict) {
+			continue
+		}
+		var out []page
+		root := refID(value(d.objects[id].dict, "/Pages"))
+		d.walk(root, resources{}, map[string]bool{}, 0, &out)
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return nil
+}
+
+// walk appends the pages under the page tree node id. Nodes without their own resources inherit those of their parent.
+func (d *document) walk(id string, inherited resources, seen map[string]bool, depth int, out *[]page) {
+	obj, ok := d.objects[id]
+	if !ok || seen[id] || depth > maxDepth {
+		return
+	}
+	seen[id] = true
+
+	res := inherited
+	if v := value(obj.dict, "/Resources"); v != "" {
+		res = d.resources(d.resolve(v))
+	}
+	if pageRe.MatchString(obj.dict) {
+		*out = append(*out, page{dict: obj.dict, resources: res})
+		return
+	}
+	for _, kid := range refs(d.resolve(value(obj.dict, "/Kids"))) {
+		d.walk(kid, res, seen, depth+1, out)
+	}
+}
+
+// resources reads the fonts and forms of a resource dictionary
+func (d *document) resources(dict string) resources {
+	res := resources{fonts: map[string]*cmap{}, forms: map[string]string{}}
+	for _, ref := range resourceRefRe.FindAllStringSubmatch(d.resolve(value(dict, "/Font")), -1) {
+		res.fonts[ref[1]] = d.cmap(ref[2])
+	}
+	for _, ref := range resourceRefRe.FindAllStringSubmatch(d.resolve(value(dict, "/XObject")), -1) {
+		if obj, ok := d.objects[ref[2]]; ok && obj.stream != nil && formRe.MatchString(obj.dict) {
+			res.forms[ref[1]] = ref[2]
+		}
+	}
+	return res
+}
+
+// cmap returns the ToUnicode map of the font object id, parsing it on first use
+func (d *document) cmap(id string) *cmap {
+	if cm, ok := d.cmaps[id]; ok {
+		return cm
+	}
+	var cm *cmap
+	if font, ok := d.objects[id]; ok {
+		if tu := toUnicodeRe.FindStringSubmatch(font.dict); tu != nil {
+			if s, ok := d.objects[tu[1]]; ok && s.stream != nil {
+				cm = parseCMap(s.stream)
+			}
+		}
+	}
+	d.cmaps[id] = cm
+	return cm
+}
+
+// contents joins the content streams of a page, which may be split across several objects
+func (d *document) contents(p page) []byte {
+	v := value(p.dict, "/Contents")
+	ids := refs(v)
+	// A single reference may point to an array of streams rather than a stream
+	if len(ids) == 1 {
+		if obj, ok := d.objects[ids[0]]; ok && obj.stream == nil {
+			ids = refs(obj.dict)
+		}
+	}
+	var out []byte
+	for _, id := range ids {
+		if obj, ok := d.objects[id]; ok && obj.stream != nil {
+			out = append(out, obj.stream...)
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
+
+// resolve returns the dictionary of the object v refers to, or v itself when it is not a reference
+func (d *document) resolve(v string) string {
+	if id := refID(v); id != "" {
+		return d.objects[id].dict
+	}
+	return v
+}
+
+// refID returns the object number of a reference such as "12 0 R", or "" when v is something else
+func refID(v string) string {
+	if m := refRe.FindStringSubmatch(strings.TrimSpace(v)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// refs returns the object numbers of every reference in v, in order
+func refs(v string) []string {
+	var out []string
+	for _, m := range refsRe.FindAllStringSubmatch(v, -1) {
+		out = append(out, m[1])
+	}
+	return out
+}
+
+// value returns the raw value of key in a dictionary: a nested dictionary or array with its brackets, a reference, or a single token. It returns "" when the key is missing.
+func value(dict, key string) string {
+	for from := 0; ; {
+		i := strings.Index(dict[from:], key)
+		if i < 0 {
+			return ""
+		}
+		rest := dict[from+i+len(key):]
+		from += i + len(key)
+		// Skip longer keys sharing the prefix, such as /FontFile when looking for /Font
+		if rest != "" && !isSpace(rest[0]) && !isDelim(rest[0]) {
+			continue
+		}
+		rest = strings.TrimLeft(rest, " \t\r\n\f\x00")
+		switch {
+		case strings.HasPrefix(rest, "<<"):
+			return balanced(rest, "<<", ">>")
+		case strings.HasPrefix(rest, "["):
+			return balanced(rest, "[", "]")
+		}
+		if m := refRe.FindString(rest); m != "" {
+			return m
+		}
+		end := 1
+		for end < len(rest) && !isSpace(rest[end]) && !isDelim(rest[end]) {
+			end++
+		}
+		return rest[:min(end, len(rest))]
+	}
+}
+
+// balanced returns the prefix of s up to the close matching the open it starts with
+func balanced(s, open, close string) string {
+	depth := 0
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], open):
+			depth++
+			i += len(open)
+		case strings.HasPrefix(s[i:], close):
+			depth--
+			i += len(close)
+			if depth == 0 {
+				return s[:i]
+			}
+		default:
+			i++
+		}
+	}
+	return s
+}
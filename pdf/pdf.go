@@ -0,0 +1,418 @@
+// Package pdf extracts the visible text from PDF files without any dependencies outside the standard library. It understands compressed content streams, object streams and ToUnicode font maps, which covers the statements utility and bank sites generate. Layout is approximated: text objects and line moves become new lines.
+package pdf
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	objectRe      = regexp.MustCompile(`(?s)(\d+)\s+\d+\s+obj\b(.*?)\bendobj`)
+	fontDictRe    = regexp.MustCompile(`(?s)/Font\s*<<(.*?)>>`)
+	resourceRefRe = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s+(\d+)\s+\d+\s+R`)
+	toUnicodeRe   = regexp.MustCompile(`/ToUnicode\s+(\d+)\s+\d+\s+R`)
+	objStmRe      = regexp.MustCompile(`/Type\s*/ObjStm`)
+	firstRe       = regexp.MustCompile(`/First\s+(\d+)`)
+	hexStringRe   = regexp.MustCompile(`<([0-9A-Fa-f]+)>`)
+	hexPairRe     = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]+)>`)
+	hexRangeRe    = regexp.MustCompile(`(?s)<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]+)>\s*(<[0-9A-Fa-f]+>|\[.*?\])`)
+)
+
+// object is one indirect object: its dictionary text and its decoded stream, if any
+type object struct {
+	dict   string
+	stream []byte
+}
+
+// cmap maps character codes of one font to text
+type cmap struct {
+	codeLen int
+	chars   map[string]string
+}
+
+// ExtractText returns the text of every page of the PDF in data, one line per text line where the layout allows it.
+func ExtractText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF")) {
+		return "", errors.New("not a PDF file")
+	}
+
+	doc := &document{objects: parseObjects(data), cmaps: map[string]*cmap{}}
+
+	var sb strings.Builder
+	if pages := doc.pages(); pages != nil {
+		// Font names such as /F1 are only meaningful within the page that declares them
+		for _, p := range pages {
+			doc.extractContent(&sb, doc.contents(p), p.resources, 0)
+		}
+	} else {
+		doc.extractLoose(&sb)
+	}
+
+	if sb.Len() == 0 {
+		return "", errors.New("no text found, the PDF may be a scanned image")
+	}
+	return sb.String(), nil
+}
+
+// parseObjects indexes every indirect object in the file, including those packed inside object streams
+func parseObjects(data []byte) map[string]object {
+	objects := map[string]object{}
+	for _, loc := range objectRe.FindAllSubmatchIndex(data, -1) {
+		id := string(data[loc[2]:loc[3]])
+		body := data[loc[4]:loc[5]]
+
+		obj := object{dict: string(body)}
+		if i := bytes.Index(body, []byte("stream")); i >= 0 {
+			obj.dict = string(body[:i])
+			raw := body[i+len("stream"):]
+			raw = bytes.TrimPrefix(raw, []byte("\r"))
+			raw = bytes.TrimPrefix(raw, []byte("\n"))
+			if j := bytes.LastIndex(raw, []byte("endstream")); j >= 0 {
+				raw = raw[:j]
+			}
+			obj.stream = decodeStream(obj.dict, raw)
+		}
+		objects[id] = obj
+	}
+
+	// Compressed object streams hold plain objects back to back after a table of object numbers and offsets
+	for _, obj := range objects {
+		if obj.stream == nil || !objStmRe.MatchString(obj.dict) {
+			continue
+		}
+		m := firstRe.FindStringSubmatch(obj.dict)
+		if m == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(m[1])
+		if first > len(obj.stream) {
+			continue
+		}
+		header := strings.Fields(string(obj.stream[:first]))
+		for i := 0; i+1 < len(header); i += 2 {
+			start, err := strconv.Atoi(header[i+1])
+			if err != nil {
+				break
+			}
+			end := len(obj.stream)
+			if i+3 < len(header) {
+				if next, err := strconv.Atoi(header[i+3]); err == nil {
+					end = first + next
+				}
+			}
+			start += first
+			if start > end || end > len(obj.stream) {
+				continue
+			}
+			if _, exists := objects[header[i]]; !exists {
+				objects[header[i]] = object{dict: string(obj.stream[start:end])}
+			}
+		}
+	}
+	return objects
+}
+
+// decodeStream undoes the Flate compression most streams use. Streams with other filters are returned as-is.
+func decodeStream(dict string, raw []byte) []byte {
+	if !strings.Contains(dict, "/FlateDecode") {
+		return raw
+	}
+	if r, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+		if out, err := io.ReadAll(r); err == nil || len(out) > 0 {
+			return out
+		}
+	}
+	// Some generators write raw deflate data without the zlib header
+	out, _ := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	return out
+}
+
+// isContentStream filters out images, fonts, cross reference and object streams
+func isContentStream(obj object) bool {
+	for _, skip := range []string{"/Image", "/ObjStm", "/XRef", "/Length1", "/Length2", "/FontFile", "/ICCBased", "/Metadata"} {
+		if strings.Contains(obj.dict, skip) {
+			return false
+		}
+	}
+	return bytes.Contains(obj.stream, []byte("BT")) && bytes.Contains(obj.stream, []byte("ET"))
+}
+
+// extractLoose is the fallback for files whose page tree cannot be followed. It reads every content stream in object order, with each font name mapped to a font declared under it somewhere in the file.
+func (d *document) extractLoose(sb *strings.Builder) {
+	res := resources{fonts: map[string]*cmap{}}
+	for _, obj := range d.objects {
+		for _, m := range fontDictRe.FindAllStringSubmatch(obj.dict, -1) {
+			for _, ref := range resourceRefRe.FindAllStringSubmatch(m[1], -1) {
+				if cm := d.cmap(ref[2]); cm != nil {
+					res.fonts[ref[1]] = cm
+				}
+			}
+		}
+	}
+	for _, id := range sortedIDs(d.objects) {
+		obj := d.objects[id]
+		if obj.stream == nil || !isContentStream(obj) {
+			continue
+		}
+		d.extractContent(sb, obj.stream, res, 0)
+	}
+}
+
+// extractContent runs the text operators of a content stream and writes the text they show. Forms the stream draws with Do are read in place, with their own resources when they have them.
+func (d *document) extractContent(sb *strings.Builder, stream []byte, res resources, depth int) {
+	lex := &lexer{data: stream}
+	var operands []token
+	var font *cmap
+	lastY := 0.0
+
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+	show := func(t token) {
+		sb.WriteString(decodeText(t.str, font))
+	}
+
+	for {
+		t, ok := lex.next()
+		if !ok {
+			break
+		}
+		if t.kind != tokOperator {
+			operands = append(operands, t)
+			continue
+		}
+
+		switch t.op {
+		case "BT":
+			lastY = 0
+		case "ET":
+			newline()
+		case "Tf":
+			font = nil
+			if len(operands) >= 2 && operands[len(operands)-2].kind == tokName {
+				font = res.fonts[operands[len(operands)-2].op]
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 && operands[len(operands)-1].num != 0 {
+				newline()
+			} else if len(operands) >= 2 && operands[len(operands)-2].num > 0 {
+				sb.WriteByte(' ')
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				if y := operands[len(operands)-1].num; y != lastY {
+					newline()
+					lastY = y
+				} else {
+					sb.WriteByte(' ')
+				}
+			}
+		case "T*":
+			newline()
+		case "Tj":
+			if len(operands) >= 1 && operands[len(operands)-1].kind == tokString {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) >= 1 && operands[len(operands)-1].kind == tokString {
+				show(operands[len(operands)-1])
+			}
+		case "Do":
+			if len(operands) >= 1 && operands[len(operands)-1].kind == tokName && depth < maxDepth {
+				if id, ok := res.forms[operands[len(operands)-1].op]; ok {
+					form := d.objects[id]
+					formRes := res
+					if v := value(form.dict, "/Resources"); v != "" {
+						formRes = d.resources(d.resolve(v))
+					}
+					d.extractContent(sb, form.stream, formRes, depth+1)
+				}
+			}
+		case "TJ":
+			for _, el := range lastArray(operands) {
+				switch el.kind {
+				case tokString:
+					show(el)
+				case tokNumber:
+					// Large negative kerning is how many generators encode a word gap
+					if el.num < -200 {
+						sb.WriteByte(' ')
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// lastArray returns the elements of the array operand closing the operand list
+func lastArray(operands []token) []token {
+	end := len(operands) - 1
+	if end < 0 || operands[end].kind != tokArrayEnd {
+		return nil
+	}
+	for i := end - 1; i >= 0; i-- {
+		if operands[i].kind == tokArrayStart {
+			return operands[i+1 : end]
+		}
+	}
+	return nil
+}
+
+// decodeText converts the bytes of a string operand to text using the font's ToUnicode map when there is one, and Latin-1 otherwise
+func decodeText(b []byte, font *cmap) string {
+	if font == nil || len(font.chars) == 0 {
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(b); i += font.codeLen {
+		end := min(i+font.codeLen, len(b))
+		if s, ok := font.chars[string(b[i:end])]; ok {
+			sb.WriteString(s)
+		} else if font.codeLen == 1 {
+			sb.WriteRune(rune(b[i]))
+		}
+	}
+	return sb.String()
+}
+
+// parseCMap reads the bfchar and bfrange sections of a ToUnicode map
+func parseCMap(data []byte) *cmap {
+	cm := &cmap{codeLen: 1, chars: map[string]string{}}
+	text := string(data)
+
+	for _, section := range sections(text, "beginbfchar", "endbfchar") {
+		for _, m := range hexPairRe.FindAllStringSubmatch(section, -1) {
+			src := hexBytes(m[1])
+			cm.codeLen = len(src)
+			cm.chars[string(src)] = utf16Hex(m[2])
+		}
+	}
+
+	for _, section := range sections(text, "beginbfrange", "endbfrange") {
+		for _, m := range hexRangeRe.FindAllStringSubmatch(section, -1) {
+			lo, hi := hexBytes(m[1]), hexBytes(m[2])
+			if len(lo) == 0 || len(lo) != len(hi) || len(lo) > 4 {
+				continue
+			}
+			cm.codeLen = len(lo)
+			from, to := bytesToInt(lo), bytesToInt(hi)
+			if to < from || to-from > 0xFFFF {
+				continue
+			}
+
+			if strings.HasPrefix(m[3], "[") {
+				dsts := hexStringRe.FindAllStringSubmatch(m[3], -1)
+				for i, d := range dsts {
+					if from+i > to {
+						break
+					}
+					cm.chars[string(intToBytes(from+i, len(lo)))] = utf16Hex(d[1])
+				}
+				continue
+			}
+
+			dst := hexBytes(strings.Trim(m[3], "<>"))
+			for code := from; code <= to; code++ {
+				cp := append([]byte(nil), dst...)
+				// The last byte of the destination is incremented across the range
+				if len(cp) > 0 {
+					cp[len(cp)-1] += byte(code - from)
+				}
+				cm.chars[string(intToBytes(code, len(lo)))] = utf16Bytes(cp)
+			}
+		}
+	}
+	return cm
+}
+
+func sections(text, begin, end string) []string {
+	var out []string
+	for {
+		i := strings.Index(text, begin)
+		if i < 0 {
+			return out
+		}
+		text = text[i+len(begin):]
+		j := strings.Index(text, end)
+		if j < 0 {
+			return append(out, text)
+		}
+		out = append(out, text[:j])
+		text = text[j+len(end):]
+	}
+}
+
+func hexBytes(s string) []byte {
+	if len(s)%2 == 1 {
+		s += "0"
+	}
+	b := make([]byte, len(s)/2)
+	for i := range b {
+		v, _ := strconv.ParseUint(s[2*i:2*i+2], 16, 8)
+		b[i] = byte(v)
+	}
+	return b
+}
+
+func utf16Hex(s string) string {
+	return utf16Bytes(hexBytes(s))
+}
+
+func utf16Bytes(b []byte) string {
+	if len(b) == 1 {
+		return string(rune(b[0]))
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+func bytesToInt(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+func intToBytes(v, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+// sortedIDs orders objects by object number, which usually follows page order
+func sortedIDs(objects map[string]object) []string {
+	ids := make([]string, 0, len(objects))
+	for id := range objects {
+		ids = append(ids, id)
+	}
+	nums := make(map[string]int, len(ids))
+	for _, id := range ids {
+		nums[id], _ = strconv.Atoi(id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return nums[ids[i]] < nums[ids[j]] })
+	return ids
+}
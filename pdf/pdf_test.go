@@ -0,0 +1,169 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes objects numbered from 1 in order, leaving out empty ones. Bodies holding a stream are given as dictionary and stream text separated by "stream\n".
+func buildPDF(objects ...string) []byte {
+	var sb strings.Builder
+	sb.WriteString("%PDF-1.4\n")
+	for i, body := range objects {
+		if body == "" {
+			continue
+		}
+		if dict, stream, ok := strings.Cut(body, "stream\n"); ok {
+			body = fmt.Sprintf("%s/Length %d >>\nstream\n%s\nendstream", strings.TrimSuffix(dict, ">>"), len(stream), stream)
+		}
+		fmt.Fprintf(&sb, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	sb.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return []byte(sb.String())
+}
+
+// toUnicode is a ToUnicode map sending the one byte code 01 to r
+func toUnicode(r rune) string {
+	return fmt.Sprintf("<< >>stream\nbegincmap\n1 beginbfchar\n<01> <%04X>\nendbfchar\nendcmap", r)
+}
+
+func TestExtractTextFontsPerPage(t *testing.T) {
+	// Both pages call their font /F1, but it is a different font on each
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 6 0 R >> >> /Contents 8 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /ToUnicode 9 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /ToUnicode 10 0 R >>",
+		"<< >>stream\nBT /F1 12 Tf <010101> Tj ET",
+		"<< >>stream\nBT /F1 12 Tf <0101> Tj ET",
+		toUnicode('A'),
+		toUnicode('B'),
+	)
+	got, err := ExtractText(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "AAA\nBB\n"; got != want {
+		t.Errorf("ExtractText() = %q, want %q", got, want)
+	}
+}
+
+func TestExtractTextInheritedResourcesAndForms(t *testing.T) {
+	// The page takes its fonts from the page tree node above it and draws part of its text through a form
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources 4 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [5 0 R 6 0 R] >>",
+		"<< /Font << /F1 7 0 R >> /XObject << /Fm1 8 0 R >> >>",
+		"<< >>stream\nBT /F1 12 Tf <01> Tj ET",
+		"<< >>stream\n/Fm1 Do",
+		"<< /Type /Font /ToUnicode 9 0 R >>",
+		"<< /Type /XObject /Subtype /Form /Resources << /Font << /F1 10 0 R >> >> >>stream\nBT /F1 12 Tf <0101> Tj ET",
+		toUnicode('P'),
+		"<< /Type /Font /ToUnicode 11 0 R >>",
+		toUnicode('Q'),
+	)
+	got, err := ExtractText(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "P\nQQ\n"; got != want {
+		t.Errorf("ExtractText() = %q, want %q", got, want)
+	}
+}
+
+func TestSortedIDs(t *testing.T) {
+	objects := map[string]object{"10": {}, "2": {}, "1": {}, "33": {}, "9": {}}
+	got := strings.Join(sortedIDs(objects), " ")
+	if want := "1 2 9 10 33"; got != want {
+		t.Errorf("sortedIDs() = %q, want %q", got, want)
+	}
+}
+
+func deflate(s string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.String()
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"lines", "BT /F1 12 Tf 72 700 Td (Amount Due) Tj 0 -14 Td ($120.50) Tj ET", "Amount Due\n$120.50\n"},
+		{"kerning and word gaps", "BT [(Tot) 20 (al) -250 (Due)] TJ ET", "Total Due\n"},
+		{"text matrix", "BT 1 0 0 1 72 700 Tm (Due) Tj 1 0 0 1 120 700 Tm (05/08/2026) Tj 1 0 0 1 72 680 Tm (Paid) Tj ET", "Due 05/08/2026\nPaid\n"},
+		{"escapes", `BT (Smith \(Rental\)\101) Tj T* <4869> Tj ET`, "Smith (Rental)A\nHi\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+				"<< /Filter /FlateDecode >>stream\n"+deflate(tt.content),
+			)
+			got, err := ExtractText(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTextObjectStream(t *testing.T) {
+	// The page tree and font live in a compressed object stream, as many generators write them
+	packed := []string{
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /ToUnicode 6 0 R >>",
+	}
+	var header, body strings.Builder
+	for i, obj := range packed {
+		fmt.Fprintf(&header, "%d %d ", i+2, body.Len())
+		body.WriteString(obj + "\n")
+	}
+	objStm := fmt.Sprintf("<< /Type /ObjStm /N 3 /First %d /Filter /FlateDecode >>stream\n%s", header.Len(), deflate(header.String()+body.String()))
+
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", "", "", // objects 2 to 4 are packed in the object stream
+		"<< >>stream\nBT /F1 12 Tf <0101> Tj ET",
+		toUnicode('Z'),
+		objStm,
+	)
+	got, err := ExtractText(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ZZ\n"; got != want {
+		t.Errorf("ExtractText() = %q, want %q", got, want)
+	}
+}
+
+func TestExtractTextErrors(t *testing.T) {
+	if _, err := ExtractText([]byte("<html>not a statement</html>")); err == nil {
+		t.Error("no error for a file that is not a PDF")
+	}
+	scanned := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		"<< >>stream\nq 612 0 0 792 0 0 cm /Im1 Do Q",
+	)
+	if _, err := ExtractText(scanned); err == nil {
+		t.Error("no error for a page without text")
+	}
+}
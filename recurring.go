@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Schedule rules supported by recurring bills
+const (
+	ruleMonthly         = "monthly"         // every month on Day
+	ruleWeekly          = "weekly"          // every Interval weeks counted from Start
+	ruleQuarterly       = "quarterly"       // every third month on Day, starting from Month
+	ruleAnnual          = "annual"          // every year on Month/Day
+	ruleLastBusinessDay = "lastBusinessDay" // the last business day of every month
+)
+
+// How an occurrence that lands on a weekend or holiday is moved
+const (
+	shiftNone     = "none"
+	shiftNext     = "next"
+	shiftPrevious = "previous"
+)
+
+// recurringBill is a bill with a known amount on a predictable schedule, configured in the config file.
+//
+// Example:
+//
+//	{"name": "Car", "amount": 422.94, "rule": "monthly", "day": 17, "end": "2028-06-17", "shift": "next"}
+type recurringBill struct {
+	Name     string  `json:"name"`
+	Amount   float64 `json:"amount"`
+	Rule     string  `json:"rule"`
+	Day      int     `json:"day,omitempty"`
+	Month    int     `json:"month,omitempty"`
+	Interval int     `json:"interval,omitempty"`
+	Start    string  `json:"start,omitempty"` // first possible payment date, required for weekly
+	End      string  `json:"end,omitempty"`   // last possible payment date, e.g. the loan payoff date
+	Shift    string  `json:"shift,omitempty"`
+
+	start time.Time
+	end   time.Time
+}
+
+func (rb *recurringBill) validate() error {
+	var err error
+	if rb.Start != "" {
+		if rb.start, err = parseConfigDate(rb.Start); err != nil {
+			return fmt.Errorf("invalid start date: %v", err)
+		}
+	}
+	if rb.End != "" {
+		if rb.end, err = parseConfigDate(rb.End); err != nil {
+			return fmt.Errorf("invalid end date: %v", err)
+		}
+	}
+
+	switch rb.Rule {
+	case ruleMonthly, ruleQuarterly:
+		if rb.Day < 1 || rb.Day > 31 {
+			return fmt.Errorf("day must be between 1 and 31")
+		}
+		if rb.Rule == ruleQuarterly && rb.Month == 0 {
+			rb.Month = 1
+		}
+		if rb.Month < 0 || rb.Month > 12 {
+			return fmt.Errorf("month must be between 1 and 12")
+		}
+	case ruleAnnual:
+		if rb.Month < 1 || rb.Month > 12 {
+			return fmt.Errorf("month must be between 1 and 12")
+		}
+		if rb.Day < 1 || rb.Day > 31 {
+			return fmt.Errorf("day must be between 1 and 31")
+		}
+	case ruleWeekly:
+		if rb.Interval == 0 {
+			rb.Interval = 1
+		}
+		if rb.Interval < 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+		if rb.start.IsZero() {
+			return fmt.Errorf("weekly bills need a start date to count weeks from")
+		}
+	case ruleLastBusinessDay:
+	default:
+		return fmt.Errorf("unknown rule %q", rb.Rule)
+	}
+
+	switch rb.Shift {
+	case "":
+		rb.Shift = shiftNone
+	case shiftNone, shiftNext, shiftPrevious:
+	default:
+		return fmt.Errorf("unknown shift %q", rb.Shift)
+	}
+
+	return nil
+}
+
+// next returns the first payment date on or after t, after weekend and holiday shifting. It returns false once the schedule has ended.
+func (rb recurringBill) next(t time.Time, holidays map[string]bool) (time.Time, bool) {
+	t = truncateDay(t)
+
+	// Shifting back can move an occurrence before t, so start looking a little early
+	nominal := rb.nominal(t.AddDate(0, 0, -7), holidays)
+	for {
+		if !rb.end.IsZero() && nominal.After(rb.end) {
+			return time.Time{}, false
+		}
+		if due := rb.shift(nominal, holidays); !due.Before(t) {
+			return due, true
+		}
+		nominal = rb.nominal(nominal.AddDate(0, 0, 1), holidays)
+	}
+}
+
+// occurrences returns every payment date in [from, to)
+func (rb recurringBill) occurrences(from, to time.Time, holidays map[string]bool) []time.Time {
+	var dates []time.Time
+	for due, ok := rb.next(from, holidays); ok && due.Before(to); due, ok = rb.next(due.AddDate(0, 0, 1), holidays) {
+		dates = append(dates, due)
+	}
+	return dates
+}
+
+// nominal returns the first scheduled date on or after t before any weekend or holiday shifting
+func (rb recurringBill) nominal(t time.Time, holidays map[string]bool) time.Time {
+	t = truncateDay(t)
+	if t.Before(rb.start) {
+		t = rb.start
+	}
+
+	switch rb.Rule {
+	case ruleWeekly:
+		period := 7 * rb.Interval
+		elapsed := daysBetween(rb.start, t)
+		periods := (elapsed + period - 1) / period
+		return rb.start.AddDate(0, 0, periods*period)
+	case ruleQuarterly:
+		due := monthlyOnDay(t, rb.Day)
+		for (int(due.Month())-rb.Month+12)%3 != 0 {
+			due = monthlyOnDay(firstOfNextMonth(due), rb.Day)
+		}
+		return due
+	case ruleAnnual:
+		due := monthlyOnDay(t, rb.Day)
+		for int(due.Month()) != rb.Month {
+			due = monthlyOnDay(firstOfNextMonth(due), rb.Day)
+		}
+		return due
+	case ruleLastBusinessDay:
+		for {
+			due := lastBusinessDay(t.Year(), t.Month(), holidays)
+			if !due.Before(t) {
+				return due
+			}
+			t = firstOfNextMonth(t)
+		}
+	default:
+		return monthlyOnDay(t, rb.Day)
+	}
+}
+
+func (rb recurringBill) shift(t time.Time, holidays map[string]bool) time.Time {
+	step := 0
+	switch rb.Shift {
+	case shiftNext:
+		step = 1
+	case shiftPrevious:
+		step = -1
+	}
+	if step == 0 {
+		return t
+	}
+	for !isBusinessDay(t, holidays) {
+		t = t.AddDate(0, 0, step)
+	}
+	return t
+}
+
+func lastBusinessDay(year int, month time.Month, holidays map[string]bool) time.Time {
+	t := time.Date(year, month, daysIn(year, month), 0, 0, 0, 0, time.Local)
+	for !isBusinessDay(t, holidays) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// isBusinessDay reports whether banks are open on t: not a weekend, not a US federal holiday and not one of the configured extra holidays
+func isBusinessDay(t time.Time, holidays map[string]bool) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	if holidays[t.Format(dateLayout)] {
+		return false
+	}
+	return !isFederalHoliday(t)
+}
+
+// isFederalHoliday reports whether t is an observed US federal holiday. Fixed-date holidays that fall on a weekend are observed on the nearest weekday.
+func isFederalHoliday(t time.Time) bool {
+	year, month, day := t.Date()
+
+	switch month {
+	case time.January:
+		if t.Weekday() == time.Monday && (day-1)/7 == 2 { // Martin Luther King Jr. Day, third Monday
+			return true
+		}
+	case time.February:
+		if t.Weekday() == time.Monday && (day-1)/7 == 2 { // Washington's Birthday, third Monday
+			return true
+		}
+	case time.May:
+		if t.Weekday() == time.Monday && day+7 > 31 { // Memorial Day, last Monday
+			return true
+		}
+	case time.September:
+		if t.Weekday() == time.Monday && day <= 7 { // Labor Day, first Monday
+			return true
+		}
+	case time.October:
+		if t.Weekday() == time.Monday && (day-1)/7 == 1 { // Columbus Day, second Monday
+			return true
+		}
+	case time.November:
+		if t.Weekday() == time.Thursday && (day-1)/7 == 3 { // Thanksgiving, fourth Thursday
+			return true
+		}
+	}
+
+	fixed := []struct {
+		month time.Month
+		day   int
+	}{
+		{time.January, 1},
+		{time.June, 19},
+		{time.July, 4},
+		{time.November, 11},
+		{time.December, 25},
+	}
+	// Check the neighbouring years too so New Year's Day observed on Dec 31 is caught
+	for y := year - 1; y <= year+1; y++ {
+		for _, f := range fixed {
+			observed := time.Date(y, f.month, f.day, 0, 0, 0, 0, time.Local)
+			switch observed.Weekday() {
+			case time.Saturday:
+				observed = observed.AddDate(0, 0, -1)
+			case time.Sunday:
+				observed = observed.AddDate(0, 0, 1)
+			}
+			if observed.Year() == year && observed.Month() == month && observed.Day() == day {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func firstOfNextMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.Local)
+}
+
+// daysBetween counts calendar days from a to b, ignoring daylight saving shifts
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := parseConfigDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func validRecurring(t *testing.T, rb recurringBill) recurringBill {
+	t.Helper()
+	if err := rb.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	return rb
+}
+
+func TestRecurringNext(t *testing.T) {
+	tests := []struct {
+		name string
+		rb   recurringBill
+		from string
+		want string // "" when the schedule has ended
+	}{
+		{"day past the end of the month", recurringBill{Rule: ruleMonthly, Day: 31}, "2026-02-01", "2026-02-28"},
+		{"day past the end of a leap month", recurringBill{Rule: ruleMonthly, Day: 31}, "2028-02-01", "2028-02-29"},
+		{"on the due date", recurringBill{Rule: ruleMonthly, Day: 17}, "2026-03-17", "2026-03-17"},
+		// Jan 1 2028 is a Saturday and New Year's Day is observed on Friday Dec 31
+		{"shift back across the year", recurringBill{Rule: ruleMonthly, Day: 1, Shift: shiftPrevious}, "2027-12-20", "2027-12-30"},
+		{"after a date shifted back across the year", recurringBill{Rule: ruleMonthly, Day: 1, Shift: shiftPrevious}, "2027-12-31", "2028-02-01"},
+		{"shift forward into the year", recurringBill{Rule: ruleMonthly, Day: 1, Shift: shiftNext}, "2027-12-20", "2028-01-03"},
+		{"last business day", recurringBill{Rule: ruleLastBusinessDay}, "2026-02-01", "2026-02-27"},
+		{"quarterly", recurringBill{Rule: ruleQuarterly, Month: 2, Day: 15}, "2026-03-01", "2026-05-15"},
+		{"annual", recurringBill{Rule: ruleAnnual, Month: 7, Day: 4, Shift: shiftNext}, "2026-01-01", "2026-07-06"},
+		{"last payment", recurringBill{Rule: ruleMonthly, Day: 17, End: "2028-06-17"}, "2028-06-01", "2028-06-17"},
+		{"after the last payment", recurringBill{Rule: ruleMonthly, Day: 17, End: "2028-06-17"}, "2028-06-18", ""},
+		{"before the start", recurringBill{Rule: ruleMonthly, Day: 5, Start: "2026-06-01"}, "2026-01-01", "2026-06-05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb := validRecurring(t, tt.rb)
+			got, ok := rb.next(date(tt.from), nil)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("next(%s) = %s, want the schedule to have ended", tt.from, got.Format(dateLayout))
+				}
+				return
+			}
+			if !ok || !got.Equal(date(tt.want)) {
+				t.Errorf("next(%s) = %s, %v, want %s", tt.from, got.Format(dateLayout), ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurringOccurrences(t *testing.T) {
+	tests := []struct {
+		name     string
+		rb       recurringBill
+		from, to string
+		want     []string
+	}{
+		{"month ends", recurringBill{Rule: ruleMonthly, Day: 31}, "2026-01-15", "2026-05-01", []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"}},
+		{"every other week", recurringBill{Rule: ruleWeekly, Interval: 2, Start: "2026-01-02"}, "2026-01-01", "2026-02-01", []string{"2026-01-02", "2026-01-16", "2026-01-30"}},
+		{"across the year", recurringBill{Rule: ruleMonthly, Day: 1, Shift: shiftPrevious}, "2027-11-15", "2028-02-15", []string{"2027-12-01", "2027-12-30", "2028-02-01"}},
+		{"until the end date", recurringBill{Rule: ruleMonthly, Day: 17, End: "2028-06-17"}, "2028-04-01", "2029-01-01", []string{"2028-04-17", "2028-05-17", "2028-06-17"}},
+		{"extra holiday", recurringBill{Rule: ruleMonthly, Day: 10, Shift: shiftNext}, "2026-04-01", "2026-05-01", []string{"2026-04-13"}},
+	}
+	holidays := map[string]bool{"2026-04-10": true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb := validRecurring(t, tt.rb)
+			var got []string
+			for _, d := range rb.occurrences(date(tt.from), date(tt.to), holidays) {
+				got = append(got, d.Format(dateLayout))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("occurrences = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("occurrences = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"billburner/cd"
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Number of past cycles drawn in each sparkline
+const sparklineCycles = 12
+
+type reportRow struct {
+	Name      string
+	Household string
+	Amount    string
+	DueDate   string
+	DaysUntil string
+	Urgency   string
+	Delta     string
+	DeltaSign string
+	Sparkline template.HTML
+	Status    string
+	Retrieved bool
+	Usage     string
+	UnitRate  string
+	Reference string
+}
+
+type reportFailure struct {
+	Name       string
+	Message    string
+	Screenshot string
+}
+
+type reportForecastLine struct {
+	Label  string
+	Amount string
+	Source string
+}
+
+type reportForecast struct {
+	Horizons   []reportForecastLine
+	PayPeriods []reportForecastLine
+	Items      []reportForecastLine
+}
+
+type reportLoan struct {
+	Name           string
+	NextPayment    string
+	Principal      string
+	Interest       string
+	Escrow         string
+	Balance        string
+	InterestToDate string
+	PayoffDate     string
+	Difference     string
+}
+
+type reportSplits struct {
+	People      []string
+	Rows        []reportSplitRow
+	Balances    []reportForecastLine
+	Settlements []string
+}
+
+type reportSplitRow struct {
+	Name   string
+	Amount string
+	Owed   []string
+}
+
+type reportData struct {
+	Generated string
+	Elapsed   string
+	Rows      []reportRow
+	Total     string
+	Failures  []reportFailure
+	Warnings  []reportFailure
+	// Past-due cycles the imported bank transactions show no payment for
+	Unreconciled []reportForecastLine
+	Forecast     reportForecast
+	Loans        []reportLoan
+	Splits       *reportSplits
+}
+
+func reportDir() string {
+	if dir := os.Getenv("REPORT_DIR"); dir != "" {
+		return dir
+	}
+	return "reports"
+}
+
+// captureFailureScreenshot saves a screenshot of the page a provider failed on and returns its path relative to the report directory
+func captureFailureScreenshot(ctx context.Context, billName string, runStart time.Time) string {
+	dir := filepath.Join(reportDir(), "screenshots")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Println("Error creating screenshot directory:", err)
+		return ""
+	}
+
+	name := fmt.Sprintf("%s-%s.png", runStart.Format("20060102-150405"), strings.ToLower(billName))
+	// The page may be what hung the run, so the screenshot gets a limit of its own
+	sctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cd.CaptureScreenshot(sctx, filepath.Join(dir, name))
+	return "screenshots/" + name
+}
+
+// writeHTMLReport renders the run as a standalone HTML page. The report is written once with a timestamped name and once as latest.html.
+func writeHTMLReport(dir string, bills []billEntry, st *store, runStart time.Time, elapsed time.Duration) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating report directory: %v", err)
+	}
+
+	data := buildReportData(bills, st, runStart, elapsed)
+
+	var sb strings.Builder
+	if err := reportTemplate.Execute(&sb, data); err != nil {
+		return fmt.Errorf("error rendering report: %v", err)
+	}
+
+	for _, name := range []string{"report-" + runStart.Format("20060102-150405") + ".html", "latest.html"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sb.String()), 0644); err != nil {
+			return fmt.Errorf("error writing report %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func buildReportData(bills []billEntry, st *store, runStart time.Time, elapsed time.Duration) reportData {
+	data := reportData{
+		Generated: runStart.Format("Mon Jan 2, 2006 3:04 PM"),
+		Elapsed:   elapsed.Round(time.Second).String(),
+	}
+
+	total := 0.0
+	for _, entry := range bills {
+		row := reportRow{
+			Name:      entry.label(),
+			Household: entry.household,
+			Amount:    fmt.Sprintf("%.2f", entry.bill.amountDue),
+			DueDate:   "N/A",
+			DaysUntil: "N/A",
+			Urgency:   "unknown",
+			Status:    entry.bill.status(),
+			Retrieved: entry.bill.retrieved,
+			Reference: entry.bill.paidReference,
+		}
+
+		if u := entry.bill.usage; u.unit != "" {
+			row.Usage = u.String()
+			row.UnitRate = fmt.Sprintf("%.4f/%s", entry.bill.unitRate(), u.unit)
+		}
+
+		if entry.bill.dueDate != 0 {
+			days := daysUntil(entry.bill.dueDate)
+			row.DueDate = time.Unix(entry.bill.dueDate, 0).Format("01/02/2006")
+			row.DaysUntil = fmt.Sprintf("%d", days)
+			row.Urgency = urgency(days)
+		}
+
+		if prev, ok := st.previousCycle(entry.key(), entry.bill.dueDate); ok && entry.bill.retrieved {
+			delta := entry.bill.amountDue - prev.AmountDue
+			row.Delta = fmt.Sprintf("%+.2f", delta)
+			switch {
+			case delta > 0:
+				row.DeltaSign = "up"
+			case delta < 0:
+				row.DeltaSign = "down"
+			}
+		}
+
+		cycles := st.cycles(entry.key())
+		if len(cycles) > sparklineCycles {
+			cycles = cycles[len(cycles)-sparklineCycles:]
+		}
+		amounts := make([]float64, len(cycles))
+		for i, rec := range cycles {
+			amounts[i] = rec.AmountDue
+		}
+		row.Sparkline = sparkline(amounts)
+
+		if !entry.bill.retrieved {
+			data.Failures = append(data.Failures, reportFailure{
+				Name:       entry.label(),
+				Message:    entry.bill.failure,
+				Screenshot: entry.bill.screenshot,
+			})
+		}
+
+		for _, w := range entry.bill.warnings {
+			data.Warnings = append(data.Warnings, reportFailure{Name: entry.label(), Message: w})
+		}
+
+		total += entry.bill.amountDue
+		data.Rows = append(data.Rows, row)
+	}
+	data.Total = fmt.Sprintf("%.2f", total)
+
+	if cfg.Bank != nil {
+		for _, rec := range unreconciledCycles(cfg.Bank, st, runStart) {
+			data.Unreconciled = append(data.Unreconciled, reportForecastLine{
+				Label:  rec.key().label() + " due " + time.Unix(rec.DueDate, 0).Format("01/02/2006"),
+				Amount: fmt.Sprintf("%.2f", rec.owed()),
+			})
+		}
+	}
+
+	fc := buildForecast(bills, st, runStart, forecastHorizons[len(forecastHorizons)-1])
+	for _, days := range forecastHorizons {
+		data.Forecast.Horizons = append(data.Forecast.Horizons, reportForecastLine{Label: fmt.Sprintf("Next %d days", days), Amount: fmt.Sprintf("%.2f", fc.total(days))})
+	}
+	for _, pp := range fc.payPeriods {
+		data.Forecast.PayPeriods = append(data.Forecast.PayPeriods, reportForecastLine{
+			Label:  pp.label(),
+			Amount: fmt.Sprintf("%.2f", pp.total),
+		})
+	}
+	for _, item := range fc.items {
+		data.Forecast.Items = append(data.Forecast.Items, reportForecastLine{
+			Label:  item.date.Format("Mon 01/02/2006") + " " + item.name,
+			Amount: fmt.Sprintf("%.2f", item.amount),
+			Source: item.source,
+		})
+	}
+
+	for _, ls := range loanSummaries(bills, runStart) {
+		loan := reportLoan{
+			Name:           ls.key.label(),
+			NextPayment:    fmt.Sprintf("%.2f", ls.next.payment+ls.next.escrow),
+			Principal:      fmt.Sprintf("%.2f", ls.next.principal),
+			Interest:       fmt.Sprintf("%.2f", ls.next.interest),
+			Escrow:         fmt.Sprintf("%.2f", ls.next.escrow),
+			Balance:        fmt.Sprintf("%.2f", ls.balance),
+			InterestToDate: fmt.Sprintf("%.2f", ls.interestToDate),
+			PayoffDate:     ls.payoffDate.Format("01/02/2006"),
+		}
+		if diff := ls.paymentDifference(); diff != 0 {
+			loan.Difference = fmt.Sprintf("%+.2f", diff)
+		}
+		data.Loans = append(data.Loans, loan)
+	}
+
+	if cfg.Splits != nil {
+		data.Splits = buildReportSplits(cfg.Splits, bills, st, runStart)
+	}
+
+	return data
+}
+
+// buildReportSplits shows how this run's bills divide between housemates along with the running balances
+func buildReportSplits(sc *splitConfig, bills []billEntry, st *store, runStart time.Time) *reportSplits {
+	splits := &reportSplits{People: sc.People}
+
+	cycles := splitCycles(sc, st)
+	for _, entry := range bills {
+		if !entry.bill.retrieved {
+			continue
+		}
+		for _, c := range cycles {
+			if c.key != entry.key() || c.dueDate != truncateDay(time.Unix(entry.bill.dueDate, 0)) {
+				continue
+			}
+			row := reportSplitRow{Name: entry.label(), Amount: fmt.Sprintf("%.2f", c.amount)}
+			for _, p := range sc.People {
+				row.Owed = append(row.Owed, fmt.Sprintf("%.2f", c.owed[p]))
+			}
+			splits.Rows = append(splits.Rows, row)
+		}
+	}
+
+	balances := splitBalances(sc, cycles, st.Payments)
+	for _, p := range sc.People {
+		splits.Balances = append(splits.Balances, reportForecastLine{Label: p, Amount: fmt.Sprintf("%+.2f", balances[p])})
+	}
+	for _, t := range settleUp(balances) {
+		splits.Settlements = append(splits.Settlements, fmt.Sprintf("%s pays %s $%.2f", t.from, t.to, t.amount))
+	}
+	return splits
+}
+
+// urgency buckets the days until a bill is due into the CSS class used to color its row
+func urgency(days int) string {
+	switch {
+	case days < 0:
+		return "overdue"
+	case days <= 3:
+		return "urgent"
+	case days <= 7:
+		return "soon"
+	default:
+		return "ok"
+	}
+}
+
+// sparkline draws the amounts as an inline SVG polyline so the report needs no external assets
+func sparkline(amounts []float64) template.HTML {
+	const width, height = 120.0, 24.0
+	if len(amounts) < 2 {
+		return ""
+	}
+
+	min, max := amounts[0], amounts[0]
+	for _, a := range amounts {
+		if a < min {
+			min = a
+		}
+		if a > max {
+			max = a
+		}
+	}
+	span := max - min
+	if span == 0 {
+		span = 1
+	}
+
+	points := make([]string, len(amounts))
+	for i, a := range amounts {
+		x := float64(i) * width / float64(len(amounts)-1)
+		y := height - 2 - (a-min)/span*(height-4)
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	return template.HTML(fmt.Sprintf(`<svg class="spark" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f"><polyline points="%s"/></svg>`,
+		width, height, width, height, strings.Join(points, " ")))
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>BillBurner Report - {{.Generated}}</title>
+<style>
+	body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; background: #f4f5f7; color: #222; margin: 2em; }
+	h1 { font-size: 1.5em; margin-bottom: 0.2em; }
+	h2 { font-size: 1.2em; margin-top: 2em; }
+	.meta { color: #666; margin-bottom: 1.5em; }
+	table { border-collapse: collapse; background: #fff; box-shadow: 0 1px 3px rgba(0,0,0,0.1); }
+	th, td { padding: 0.5em 1em; text-align: left; border-bottom: 1px solid #e3e5e8; }
+	th { background: #2d3748; color: #fff; font-weight: 600; }
+	td.num { text-align: right; font-variant-numeric: tabular-nums; }
+	tr.overdue td { background: #fed7d7; }
+	tr.urgent td { background: #feebc8; }
+	tr.soon td { background: #fefcbf; }
+	tr.ok td { background: #f0fff4; }
+	tr.failed td { color: #999; font-style: italic; }
+	tr.total td { font-weight: 700; background: #edf2f7; }
+	td.status-paid { color: #2f855a; font-weight: 600; }
+	td.status-scheduled { color: #2b6cb0; }
+	td.status-unpaid { color: #c53030; }
+	.up { color: #c53030; }
+	.down { color: #2f855a; }
+	svg.spark polyline { fill: none; stroke: #4a5568; stroke-width: 1.5; }
+	.failure { background: #fff; border-left: 4px solid #c53030; padding: 0.8em 1em; margin-bottom: 1em; }
+	.forecast { display: flex; gap: 2em; align-items: flex-start; flex-wrap: wrap; }
+	td.estimated { color: #718096; font-style: italic; }
+	.failure.warning { border-left-color: #d69e2e; }
+	.failure img { max-width: 480px; display: block; margin-top: 0.5em; border: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>BillBurner Report</h1>
+<div class="meta">Generated {{.Generated}} &middot; run took {{.Elapsed}}</div>
+
+<table>
+	<tr><th>Bill Type</th><th>Household</th><th>Amount Due ($)</th><th>Change ($)</th><th>Due Date</th><th>Days Until Due</th><th>Status</th><th>Usage</th><th>Rate ($)</th><th>History</th></tr>
+	{{- range .Rows}}
+	<tr class="{{if .Retrieved}}{{.Urgency}}{{else}}failed{{end}}">
+		<td>{{.Name}}</td>
+		<td>{{.Household}}</td>
+		<td class="num">{{.Amount}}</td>
+		<td class="num {{.DeltaSign}}">{{.Delta}}</td>
+		<td>{{.DueDate}}</td>
+		<td class="num">{{.DaysUntil}}</td>
+		<td class="status-{{.Status}}"{{if .Reference}} title="{{.Reference}}"{{end}}>{{.Status}}</td>
+		<td class="num">{{.Usage}}</td>
+		<td class="num">{{.UnitRate}}</td>
+		<td>{{.Sparkline}}</td>
+	</tr>
+	{{- end}}
+	<tr class="total"><td>Total</td><td></td><td class="num">{{.Total}}</td><td></td><td></td><td></td><td></td><td></td><td></td><td></td></tr>
+</table>
+
+<h2>Cash-Flow Forecast</h2>
+<div class="forecast">
+<table>
+	<tr><th>Horizon</th><th>Cash Needed ($)</th></tr>
+	{{- range .Forecast.Horizons}}
+	<tr><td>{{.Label}}</td><td class="num">{{.Amount}}</td></tr>
+	{{- end}}
+</table>
+<table>
+	<tr><th>Pay Period</th><th>Outflow ($)</th></tr>
+	{{- range .Forecast.PayPeriods}}
+	<tr><td>{{.Label}}</td><td class="num">{{.Amount}}</td></tr>
+	{{- end}}
+</table>
+<table>
+	<tr><th>Due</th><th>Amount ($)</th><th>Source</th></tr>
+	{{- range .Forecast.Items}}
+	<tr><td>{{.Label}}</td><td class="num">{{.Amount}}</td><td class="{{.Source}}">{{.Source}}</td></tr>
+	{{- end}}
+</table>
+</div>
+
+{{- if .Loans}}
+<h2>Loans</h2>
+<table>
+	<tr><th>Loan</th><th>Next Payment ($)</th><th>Principal ($)</th><th>Interest ($)</th><th>Escrow ($)</th><th>Balance ($)</th><th>Interest To Date ($)</th><th>Payoff Date</th><th>Billed vs Scheduled ($)</th></tr>
+	{{- range .Loans}}
+	<tr>
+		<td>{{.Name}}</td>
+		<td class="num">{{.NextPayment}}</td>
+		<td class="num">{{.Principal}}</td>
+		<td class="num">{{.Interest}}</td>
+		<td class="num">{{.Escrow}}</td>
+		<td class="num">{{.Balance}}</td>
+		<td class="num">{{.InterestToDate}}</td>
+		<td>{{.PayoffDate}}</td>
+		<td class="num">{{.Difference}}</td>
+	</tr>
+	{{- end}}
+</table>
+{{- end}}
+
+{{- with .Splits}}
+<h2>Housemate Splits</h2>
+<div class="forecast">
+<table>
+	<tr><th>Bill</th><th>Amount ($)</th>{{range .People}}<th>{{.}} ($)</th>{{end}}</tr>
+	{{- range .Rows}}
+	<tr><td>{{.Name}}</td><td class="num">{{.Amount}}</td>{{range .Owed}}<td class="num">{{.}}</td>{{end}}</tr>
+	{{- end}}
+</table>
+<table>
+	<tr><th>Person</th><th>Balance ($)</th></tr>
+	{{- range .Balances}}
+	<tr><td>{{.Label}}</td><td class="num">{{.Amount}}</td></tr>
+	{{- end}}
+</table>
+<div>
+	<strong>Settle up</strong>
+	<ul>
+	{{- range .Settlements}}
+		<li>{{.}}</li>
+	{{- else}}
+		<li>Everyone is settled up.</li>
+	{{- end}}
+	</ul>
+</div>
+</div>
+{{- end}}
+
+{{- if .Failures}}
+<h2>Failures</h2>
+{{- range .Failures}}
+<div class="failure">
+	<strong>{{.Name}}</strong>: {{if .Message}}{{.Message}}{{else}}not retrieved{{end}}
+	{{- if .Screenshot}}
+	<a href="{{.Screenshot}}"><img src="{{.Screenshot}}" alt="{{.Name}} failure screenshot"></a>
+	{{- end}}
+</div>
+{{- end}}
+{{- end}}
+
+{{- if .Unreconciled}}
+<h2>Unpaid According To The Bank</h2>
+<table>
+	<tr><th>Bill</th><th>Billed ($)</th></tr>
+	{{- range .Unreconciled}}
+	<tr><td>{{.Label}}</td><td class="num">{{.Amount}}</td></tr>
+	{{- end}}
+</table>
+{{- end}}
+
+{{- if .Warnings}}
+<h2>Warnings</h2>
+{{- range .Warnings}}
+<div class="failure warning">
+	<strong>{{.Name}}</strong>: {{.Message}}
+</div>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
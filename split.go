@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// How a bill is divided between housemates
+const (
+	splitEqual     = "equal"     // everyone pays the same share
+	splitPercent   = "percent"   // Shares holds each person's percentage
+	splitFixed     = "fixed"     // Shares holds fixed dollar amounts, the remainder is split equally among everyone else
+	splitOccupancy = "occupancy" // shares follow the days each person lived in the house during the billing cycle
+)
+
+// splitConfig describes who shares the bills and how.
+//
+// Example:
+//
+//	{
+//	  "people": ["Alex", "Sam", "Jordan"],
+//	  "since": "2026-01-01",
+//	  "rules": [
+//	    {"bill": "Power", "method": "occupancy"},
+//	    {"bill": "Internet", "method": "fixed", "shares": {"Jordan": 20}},
+//	    {"bill": "Water", "method": "percent", "shares": {"Alex": 50, "Sam": 25, "Jordan": 25}}
+//	  ],
+//	  "occupancy": [{"person": "Jordan", "from": "2026-03-01"}]
+//	}
+type splitConfig struct {
+	People    []string       `json:"people"`
+	Since     string         `json:"since,omitempty"` // cycles due before this date are not part of the ledger
+	Rules     []splitRule    `json:"rules"`
+	Occupancy []occupancyRun `json:"occupancy,omitempty"`
+
+	since time.Time
+}
+
+type splitRule struct {
+	Bill    string             `json:"bill"`
+	Account string             `json:"account,omitempty"` // a rule for a specific account wins over one for the whole bill type
+	Method  string             `json:"method"`
+	People  []string           `json:"people,omitempty"` // who shares this bill, everyone by default
+	Shares  map[string]float64 `json:"shares,omitempty"`
+}
+
+// occupancyRun is a stretch of days a person lived in the house. People without any runs are treated as always present.
+type occupancyRun struct {
+	Person string `json:"person"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"` // inclusive
+
+	from time.Time
+	to   time.Time
+}
+
+// splitPayment is money one housemate put toward the shared bills, either paying a provider or paying another housemate back
+type splitPayment struct {
+	Person string  `json:"person"`
+	Amount float64 `json:"amount"`
+	Date   int64   `json:"date"`
+	Memo   string  `json:"memo,omitempty"`
+	To     string  `json:"to,omitempty"` // set when paying another housemate back rather than a provider
+}
+
+// splitCycle is what each person owes for one billing cycle
+type splitCycle struct {
+	key     billKey
+	dueDate time.Time
+	amount  float64
+	owed    map[string]float64
+}
+
+func (sc *splitConfig) validate() error {
+	known := map[string]bool{}
+	for _, p := range sc.People {
+		known[p] = true
+	}
+
+	var err error
+	if sc.Since != "" {
+		if sc.since, err = parseConfigDate(sc.Since); err != nil {
+			return fmt.Errorf("invalid since date: %v", err)
+		}
+	}
+
+	for _, rule := range sc.Rules {
+		for _, p := range rule.People {
+			if !known[p] {
+				return fmt.Errorf("rule for %s names unknown person %q", rule.Bill, p)
+			}
+		}
+		for p := range rule.Shares {
+			if !known[p] {
+				return fmt.Errorf("rule for %s names unknown person %q", rule.Bill, p)
+			}
+		}
+
+		switch rule.Method {
+		case splitEqual, splitFixed, splitOccupancy:
+		case splitPercent:
+			total := 0.0
+			for _, pct := range rule.Shares {
+				total += pct
+			}
+			if math.Abs(total-100) > 0.01 {
+				return fmt.Errorf("percent shares for %s add up to %.2f, not 100", rule.Bill, total)
+			}
+		default:
+			return fmt.Errorf("unknown split method %q for %s", rule.Method, rule.Bill)
+		}
+	}
+
+	for i := range sc.Occupancy {
+		run := &sc.Occupancy[i]
+		if !known[run.Person] {
+			return fmt.Errorf("occupancy names unknown person %q", run.Person)
+		}
+		if run.From != "" {
+			if run.from, err = parseConfigDate(run.From); err != nil {
+				return fmt.Errorf("invalid occupancy date: %v", err)
+			}
+		}
+		if run.To != "" {
+			if run.to, err = parseConfigDate(run.To); err != nil {
+				return fmt.Errorf("invalid occupancy date: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rule finds the split rule for a bill series
+func (sc *splitConfig) rule(key billKey) (splitRule, bool) {
+	var match splitRule
+	found := false
+	for _, rule := range sc.Rules {
+		if rule.Bill != key.Type {
+			continue
+		}
+		if rule.Account == key.Account {
+			return rule, true
+		}
+		if rule.Account == "" {
+			match, found = rule, true
+		}
+	}
+	return match, found
+}
+
+// split divides one cycle of a bill. The cycle runs from the day after periodStart through periodEnd and is only used by occupancy rules.
+func (sc *splitConfig) split(rule splitRule, amount float64, periodStart, periodEnd time.Time) map[string]float64 {
+	people := rule.People
+	if len(people) == 0 {
+		people = sc.People
+	}
+	owed := map[string]float64{}
+	if len(people) == 0 {
+		return owed
+	}
+
+	switch rule.Method {
+	case splitPercent:
+		for _, p := range people {
+			owed[p] = amount * rule.Shares[p] / 100
+		}
+	case splitFixed:
+		remainder := amount
+		var rest []string
+		for _, p := range people {
+			if share, ok := rule.Shares[p]; ok {
+				// Fixed amounts never take more than is left of the bill, so no one is left with a negative share
+				share = math.Max(0, math.Min(share, remainder))
+				owed[p] = share
+				remainder -= share
+			} else {
+				rest = append(rest, p)
+			}
+		}
+		if len(rest) == 0 {
+			rest = people
+		}
+		for _, p := range rest {
+			owed[p] += remainder / float64(len(rest))
+		}
+	case splitOccupancy:
+		days := map[string]int{}
+		total := 0
+		for _, p := range people {
+			days[p] = sc.daysPresent(p, periodStart, periodEnd)
+			total += days[p]
+		}
+		if total > 0 {
+			for _, p := range people {
+				owed[p] = amount * float64(days[p]) / float64(total)
+			}
+			break
+		}
+		fallthrough
+	default:
+		for _, p := range people {
+			owed[p] = amount / float64(len(people))
+		}
+	}
+
+	// Rounding each share on its own can lose or gain a cent, so the last person with a share takes whatever rounding left over
+	last := ""
+	total := 0.0
+	for _, p := range people {
+		total += owed[p]
+		if owed[p] != 0 {
+			last = p
+		}
+	}
+	rounded := 0.0
+	for _, p := range people {
+		if p != last {
+			owed[p] = roundCents(owed[p])
+			rounded += owed[p]
+		}
+	}
+	if last != "" {
+		owed[last] = roundCents(total - rounded)
+	}
+	return owed
+}
+
+// daysPresent counts the days in (periodStart, periodEnd] a person lived in the house
+func (sc *splitConfig) daysPresent(person string, periodStart, periodEnd time.Time) int {
+	var runs []occupancyRun
+	for _, run := range sc.Occupancy {
+		if run.Person == person {
+			runs = append(runs, run)
+		}
+	}
+	if len(runs) == 0 {
+		return daysBetween(periodStart, periodEnd)
+	}
+
+	days := 0
+	for d := periodStart.AddDate(0, 0, 1); !d.After(periodEnd); d = d.AddDate(0, 0, 1) {
+		for _, run := range runs {
+			if (run.from.IsZero() || !d.Before(run.from)) && (run.to.IsZero() || !d.After(run.to)) {
+				days++
+				break
+			}
+		}
+	}
+	return days
+}
+
+// splitCycles splits every stored cycle of every bill that has a split rule, oldest first
+func splitCycles(sc *splitConfig, st *store) []splitCycle {
+	var cycles []splitCycle
+	for _, key := range st.keys() {
+		rule, ok := sc.rule(key)
+		if !ok {
+			continue
+		}
+
+		records := st.cycles(key)
+		for i, rec := range records {
+			due := truncateDay(time.Unix(rec.DueDate, 0))
+			if !sc.since.IsZero() && due.Before(sc.since) {
+				continue
+			}
+			// A cycle runs from the previous due date, or roughly a month back for the first one we know about
+			periodStart := due.AddDate(0, -1, 0)
+			if i > 0 {
+				periodStart = truncateDay(time.Unix(records[i-1].DueDate, 0))
+			}
+			cycles = append(cycles, splitCycle{
+				key:     key,
+				dueDate: due,
+				amount:  rec.AmountDue,
+				owed:    sc.split(rule, rec.AmountDue, periodStart, due),
+			})
+		}
+	}
+	sort.SliceStable(cycles, func(i, j int) bool { return cycles[i].dueDate.Before(cycles[j].dueDate) })
+	return cycles
+}
+
+// splitBalances nets what everyone paid against what they owe. Positive balances are owed money, negative balances owe money.
+func splitBalances(sc *splitConfig, cycles []splitCycle, payments []splitPayment) map[string]float64 {
+	balances := map[string]float64{}
+	for _, p := range sc.People {
+		balances[p] = 0
+	}
+	for _, c := range cycles {
+		for p, v := range c.owed {
+			balances[p] -= v
+		}
+	}
+	for _, pay := range payments {
+		balances[pay.Person] += pay.Amount
+		if pay.To != "" {
+			balances[pay.To] -= pay.Amount
+		}
+	}
+	for p, v := range balances {
+		balances[p] = roundCents(v)
+	}
+	return balances
+}
+
+// settlement is one transfer needed to settle up
+type settlement struct {
+	from   string
+	to     string
+	amount float64
+}
+
+// settleUp turns balances into the fewest practical transfers by repeatedly matching the largest debtor with the largest creditor
+func settleUp(balances map[string]float64) []settlement {
+	type party struct {
+		name   string
+		amount float64
+	}
+	var debtors, creditors []party
+	for name, v := range balances {
+		switch {
+		case v < -0.005:
+			debtors = append(debtors, party{name, -v})
+		case v > 0.005:
+			creditors = append(creditors, party{name, v})
+		}
+	}
+	byAmount := func(ps []party) {
+		sort.Slice(ps, func(i, j int) bool {
+			if ps[i].amount != ps[j].amount {
+				return ps[i].amount > ps[j].amount
+			}
+			return ps[i].name < ps[j].name
+		})
+	}
+	byAmount(debtors)
+	byAmount(creditors)
+
+	var transfers []settlement
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		amount := math.Min(debtors[i].amount, creditors[j].amount)
+		transfers = append(transfers, settlement{debtors[i].name, creditors[j].name, roundCents(amount)})
+		debtors[i].amount -= amount
+		creditors[j].amount -= amount
+		if debtors[i].amount < 0.005 {
+			i++
+		}
+		if creditors[j].amount < 0.005 {
+			j++
+		}
+	}
+	return transfers
+}
+
+func renderSplits(sc *splitConfig, st *store) {
+	cycles := splitCycles(sc, st)
+
+	header := append([]string{"Due Date", "Bill", "Amount ($)"}, sc.People...)
+	rows := [][]string{header}
+	for _, c := range cycles {
+		row := []string{c.dueDate.Format("01/02/2006"), c.key.label(), fmt.Sprintf("%.2f", c.amount)}
+		for _, p := range sc.People {
+			row = append(row, fmt.Sprintf("%.2f", c.owed[p]))
+		}
+		rows = append(rows, row)
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+
+	rows = [][]string{{"Date", "Person", "Paid ($)", "To", "Memo"}}
+	for _, pay := range st.Payments {
+		rows = append(rows, []string{time.Unix(pay.Date, 0).Format("01/02/2006"), pay.Person, fmt.Sprintf("%.2f", pay.Amount), pay.To, pay.Memo})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+
+	balances := splitBalances(sc, cycles, st.Payments)
+	rows = [][]string{{"Person", "Balance ($)"}}
+	for _, p := range sc.People {
+		rows = append(rows, []string{p, fmt.Sprintf("%+.2f", balances[p])})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+
+	transfers := settleUp(balances)
+	if len(transfers) == 0 {
+		fmt.Println("Everyone is settled up.")
+		return
+	}
+	for _, t := range transfers {
+		fmt.Printf("%s pays %s $%.2f\n", t.from, t.to, t.amount)
+	}
+}
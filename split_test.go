@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSplitAddsUpToBill(t *testing.T) {
+	sc := &splitConfig{People: []string{"Alex", "Sam", "Jordan"}}
+	start := time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name   string
+		rule   splitRule
+		amount float64
+		want   map[string]float64
+	}{
+		{"equal thirds", splitRule{Method: splitEqual}, 100, map[string]float64{"Alex": 33.33, "Sam": 33.33, "Jordan": 33.34}},
+		{"percent", splitRule{Method: splitPercent, Shares: map[string]float64{"Alex": 33.3, "Sam": 33.3, "Jordan": 33.4}}, 10.01, map[string]float64{"Alex": 3.33, "Sam": 3.33, "Jordan": 3.35}},
+		{"fixed share", splitRule{Method: splitFixed, Shares: map[string]float64{"Jordan": 20}}, 100, map[string]float64{"Alex": 40, "Sam": 40, "Jordan": 20}},
+		{"fixed share above the bill", splitRule{Method: splitFixed, Shares: map[string]float64{"Jordan": 80}}, 50, map[string]float64{"Alex": 0, "Sam": 0, "Jordan": 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sc.split(tt.rule, tt.amount, start, end)
+			total := 0.0
+			for p, want := range tt.want {
+				if math.Abs(got[p]-want) > 0.001 {
+					t.Errorf("%s owes %.2f, want %.2f", p, got[p], want)
+				}
+				total += got[p]
+			}
+			if math.Abs(total-tt.amount) > 0.001 {
+				t.Errorf("shares add up to %.2f, want %.2f", total, tt.amount)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"billburner/pdf"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// Capture groups shared by the statement templates
+const (
+	statementAmount = `(\$\s*[0-9,]+\.[0-9]{2})`
+	statementDate   = `([A-Z][a-z]{2,8}\.? \d{1,2},? \d{4}|\d{1,2}/\d{1,2}/\d{2,4})`
+)
+
+// statementTemplate holds the patterns that find the figures on one provider's PDF statement. Each pattern is a label; the value capture group is appended to it.
+type statementTemplate struct {
+	amountDue *regexp.Regexp
+	dueDate   *regexp.Regexp
+	period    *regexp.Regexp // start and end date of the billing period
+	usage     *regexp.Regexp // quantity and unit
+}
+
+func newStatementTemplate(amountDue, dueDate, period, usage string) statementTemplate {
+	t := statementTemplate{
+		amountDue: regexp.MustCompile(`(?i)` + amountDue + `[^$]{0,40}?` + statementAmount),
+		dueDate:   regexp.MustCompile(`(?i)` + dueDate + `[^0-9A-Za-z]{0,10}(?:on or before\s+)?` + statementDate),
+		period:    regexp.MustCompile(`(?i)` + period + `[^0-9A-Za-z]{0,10}` + statementDate + `\s*(?:-|–|to|through|thru)\s*` + statementDate),
+	}
+	if usage != "" {
+		t.usage = regexp.MustCompile(`(?i)([0-9][0-9,]*(?:\.[0-9]+)?)\s*(` + usage + `)\b`)
+	}
+	return t
+}
+
+// statementTemplates are keyed by provider id. Providers without their own entry use the default template.
+var statementTemplates = map[string]statementTemplate{
+	"ameren":    newStatementTemplate(`total amount due`, `(?:due date|please pay by)`, `(?:service period|billing period|service from)`, `kWh`),
+	"spire":     newStatementTemplate(`(?:total amount due|amount due)`, `(?:due date|due by)`, `(?:service period|billing period|service from)`, `therms|CCF`),
+	"stlo":      newStatementTemplate(`(?:total amount due|amount due)`, `(?:due date|due by)`, `(?:service period|billing period|service from|read dates)`, `gallons|gal|CCF`),
+	"stlmsd":    newStatementTemplate(`(?:total amount due|amount due)`, `(?:due date|payment due)`, `(?:service period|billing period|service from)`, `CCF`),
+	"att":       newStatementTemplate(`total due`, `(?:due by|payment due)`, `(?:billing period|bill period)`, ""),
+	"pennymac":  newStatementTemplate(`(?:total amount due|amount due)`, `(?:payment due date|due date)`, `statement period`, ""),
+	"statefarm": newStatementTemplate(`(?:amount due|minimum due)`, `(?:due date|due on)`, `(?:policy period|billing period)`, ""),
+}
+
+var defaultStatementTemplate = newStatementTemplate(`(?:total amount due|amount due|total due)`, `(?:due date|due by|pay by)`, `(?:billing period|service period|statement period)`, `kWh|therms|CCF|gallons`)
+
+// statementData is what could be read from a statement. Fields the template did not find are left empty.
+type statementData struct {
+	amountDue   float64
+	dueDate     int64
+	periodStart int64
+	periodEnd   int64
+	usage       float64
+	usageUnit   string
+}
+
+func templateFor(provider string) statementTemplate {
+	if t, ok := statementTemplates[provider]; ok {
+		return t
+	}
+	return defaultStatementTemplate
+}
+
+// readStatement extracts the text of a PDF statement and picks out the figures the provider's template knows about
+func readStatement(provider, path string) (statementData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return statementData{}, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	text, err := pdf.ExtractText(data)
+	if err != nil {
+		return statementData{}, fmt.Errorf("error extracting text from %s: %v", path, err)
+	}
+	return parseStatement(provider, text), nil
+}
+
+func parseStatement(provider, text string) statementData {
+	t := templateFor(provider)
+	var sd statementData
+
+	if m := t.amountDue.FindStringSubmatch(text); m != nil {
+		sd.amountDue = stringToFloat(m[1])
+	}
+	if m := t.dueDate.FindStringSubmatch(text); m != nil {
+		// Shifted like the dashboards' due dates from parseDate, so the two can be compared
+		if due := parsePaymentDate(m[1]); due != 0 {
+			sd.dueDate = time.Unix(due, 0).AddDate(0, 0, 1).Unix()
+		}
+	}
+	if m := t.period.FindStringSubmatch(text); m != nil {
+		sd.periodStart = parsePaymentDate(m[1])
+		sd.periodEnd = parsePaymentDate(m[2])
+	}
+	if t.usage != nil {
+		if m := t.usage.FindStringSubmatch(text); m != nil {
+			if v, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", ""), 64); err == nil {
+				sd.usage = v
+				sd.usageUnit = m[2]
+			}
+		}
+	}
+	return sd
+}
+
+// reconcileStatement checks the scraped bill against its statement. When the dashboard could not be scraped the statement's figures are used instead; when both are available any disagreement is noted for the run report.
+func reconcileStatement(entry billEntry, sd statementData) {
+	bill := entry.bill
+	if unit, ok := usageUnits[entry.name]; ok && bill.usage.unit == "" && sd.usageUnit != "" {
+		if v, ok := normalizeUsage(sd.usage, sd.usageUnit, unit); ok {
+			bill.usage = usage{roundCents(v), unit, sd.periodStart, sd.periodEnd}
+		}
+	}
+
+	if sd.amountDue == 0 && sd.dueDate == 0 {
+		return
+	}
+
+	if !bill.retrieved {
+		if sd.amountDue == 0 || sd.dueDate == 0 {
+			return
+		}
+		bill.amountDue = sd.amountDue
+		bill.amountParsed = true
+		bill.dueDate = sd.dueDate
+		bill.retrieved = true
+		bill.warn("dashboard could not be scraped, amount and due date read from the PDF statement")
+		return
+	}
+
+	if sd.amountDue != 0 && math.Abs(sd.amountDue-bill.amountDue) >= 0.01 {
+		bill.warn("statement shows $%.2f due but the dashboard shows $%.2f", sd.amountDue, bill.amountDue)
+	}
+	if sd.dueDate != 0 && bill.dueDate != 0 && sd.dueDate != bill.dueDate {
+		bill.warn("statement is due %s but the dashboard says %s", time.Unix(sd.dueDate, 0).Format("01/02/2006"), time.Unix(bill.dueDate, 0).Format("01/02/2006"))
+	}
+}
+
+// statementCommand lists archived statements, or with "statements extract <file> [provider]" shows what the provider's template reads from a PDF
+func statementCommand(args []string) error {
+	if len(args) > 0 && args[0] == "extract" {
+		if len(args) < 2 {
+			return fmt.Errorf("usage: statements extract <file> [provider]")
+		}
+		provider := ""
+		if len(args) > 2 {
+			provider = args[2]
+		}
+		sd, err := readStatement(provider, args[1])
+		if err != nil {
+			return err
+		}
+		renderStatementData(sd)
+		return nil
+	}
+
+	st, err := loadStore(storePath())
+	if err != nil {
+		return err
+	}
+	renderStatements(st)
+	return nil
+}
+
+func renderStatementData(sd statementData) {
+	date := func(d int64) string {
+		if d == 0 {
+			return "N/A"
+		}
+		return time.Unix(d, 0).Format("01/02/2006")
+	}
+	used := "N/A"
+	if sd.usageUnit != "" {
+		used = fmt.Sprintf("%g %s", sd.usage, sd.usageUnit)
+	}
+	rows := [][]string{
+		{"Field", "Value"},
+		{"Amount Due ($)", fmt.Sprintf("%.2f", sd.amountDue)},
+		{"Due Date", date(sd.dueDate)},
+		{"Period Start", date(sd.periodStart)},
+		{"Period End", date(sd.periodEnd)},
+		{"Usage", used},
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+}
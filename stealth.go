@@ -0,0 +1,58 @@
+package main
+
+import (
+	"billburner/cd"
+	"fmt"
+
+	"github.com/pterm/pterm"
+)
+
+// stealthCommand lists the stealth evasions in use, or with "stealth test" opens the browser the way a run would and reports which bot detection checks it passes
+func stealthCommand(args []string) error {
+	opts := browserOptions()
+	enabled := map[cd.Evasion]bool{}
+	for _, e := range opts.Evasions {
+		enabled[e] = true
+	}
+
+	if len(args) == 0 {
+		rows := [][]string{{"Evasion", "Enabled"}}
+		for _, e := range cd.Evasions {
+			on := "no"
+			if len(opts.Evasions) == 0 || enabled[e] {
+				on = "yes"
+			}
+			rows = append(rows, []string{string(e), on})
+		}
+		pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+		return nil
+	}
+	if args[0] != "test" {
+		return fmt.Errorf("unknown stealth command %q", args[0])
+	}
+
+	ctx, closeBrowser, err := openBrowser()
+	if err != nil {
+		return fmt.Errorf("error creating browser: %v", err)
+	}
+	defer closeBrowser()
+
+	checks, err := cd.StealthSelfTest(ctx, 30000)
+	if err != nil {
+		return err
+	}
+
+	passed := 0
+	rows := [][]string{{"Check", "Evasion", "Result", "Detail"}}
+	for _, c := range checks {
+		result := pterm.Red("FAIL")
+		if c.Pass {
+			result = pterm.Green("pass")
+			passed++
+		}
+		rows = append(rows, []string{c.Name, string(c.Evasion), result, c.Detail})
+	}
+	pterm.DefaultTable.WithHasHeader(true).WithData(rows).Render()
+	fmt.Printf("%d of %d checks passed\n", passed, len(checks))
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// store is the local JSON file BillBurner keeps between runs. It holds every bill that was successfully retrieved so reports can show history.
+type store struct {
+	path         string
+	Records      []billRecord      `json:"records"`
+	Payments     []splitPayment    `json:"payments,omitempty"`
+	Statements   []statementRecord `json:"statements,omitempty"`
+	Transactions []bankTransaction `json:"transactions,omitempty"`
+	Matches      []bankMatch       `json:"matches,omitempty"`
+}
+
+// billRecord is a single retrieved bill as it was seen on one run.
+type billRecord struct {
+	Type        string  `json:"type"`
+	Provider    string  `json:"provider,omitempty"`
+	Account     string  `json:"account,omitempty"`
+	Household   string  `json:"household,omitempty"`
+	AmountDue   float64 `json:"amountDue"`
+	DueDate     int64   `json:"dueDate"`
+	RetrievedAt int64   `json:"retrievedAt"`
+
+	PrincipalBalance float64 `json:"principalBalance,omitempty"`
+
+	Status               string  `json:"status,omitempty"`
+	StatementBalance     float64 `json:"statementBalance,omitempty"`
+	CurrentBalance       float64 `json:"currentBalance,omitempty"`
+	LastPaymentAmount    float64 `json:"lastPaymentAmount,omitempty"`
+	LastPaymentDate      int64   `json:"lastPaymentDate,omitempty"`
+	Autopay              bool    `json:"autopay,omitempty"`
+	ScheduledPaymentDate int64   `json:"scheduledPaymentDate,omitempty"`
+
+	Usage       float64 `json:"usage,omitempty"`
+	UsageUnit   string  `json:"usageUnit,omitempty"`
+	PeriodStart int64   `json:"periodStart,omitempty"`
+	PeriodEnd   int64   `json:"periodEnd,omitempty"`
+}
+
+// billKey identifies the bills that make up one series of billing cycles: one bill type at one account
+type billKey struct {
+	Type    string
+	Account string
+}
+
+func (k billKey) label() string {
+	if k.Account == "" {
+		return k.Type
+	}
+	return k.Type + " (" + k.Account + ")"
+}
+
+func (rec billRecord) key() billKey {
+	return billKey{rec.Type, rec.Account}
+}
+
+func (e billEntry) key() billKey {
+	return billKey{e.name, e.account}
+}
+
+func storePath() string {
+	if path := os.Getenv("BILLBURNER_STORE"); path != "" {
+		return path
+	}
+	return "store.json"
+}
+
+// loadStore reads the store from disk. A missing file yields an empty store.
+func loadStore(path string) (*store, error) {
+	st := &store{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading store %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("error parsing store %s: %v", path, err)
+	}
+	return st, nil
+}
+
+func (st *store) save() error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding store: %v", err)
+	}
+	// Write to a temporary file first so a crash never leaves a truncated store behind
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing store %s: %v", tmp, err)
+	}
+	return os.Rename(tmp, st.path)
+}
+
+// addRun records every retrieved bill from a run.
+func (st *store) addRun(bills []billEntry, at time.Time) {
+	for _, entry := range bills {
+		if !entry.bill.retrieved {
+			continue
+		}
+		st.Records = append(st.Records, billRecord{
+			Type:        entry.name,
+			Provider:    entry.provider,
+			Account:     entry.account,
+			Household:   entry.household,
+			AmountDue:   entry.bill.amountDue,
+			DueDate:     entry.bill.dueDate,
+			RetrievedAt: at.Unix(),
+
+			PrincipalBalance: entry.bill.principalBalance,
+
+			Status:               entry.bill.status(),
+			StatementBalance:     entry.bill.statementBalance,
+			CurrentBalance:       entry.bill.currentBalance,
+			LastPaymentAmount:    entry.bill.lastPaymentAmount,
+			LastPaymentDate:      entry.bill.lastPaymentDate,
+			Autopay:              entry.bill.autopay,
+			ScheduledPaymentDate: entry.bill.scheduledPaymentDate,
+
+			Usage:       entry.bill.usage.value,
+			UsageUnit:   entry.bill.usage.unit,
+			PeriodStart: entry.bill.usage.periodStart,
+			PeriodEnd:   entry.bill.usage.periodEnd,
+		})
+	}
+}
+
+// cycles returns one record per billing cycle (identified by due date) for a bill series, oldest first. When a cycle was retrieved more than once the latest retrieval wins.
+func (st *store) cycles(key billKey) []billRecord {
+	byDue := map[int64]billRecord{}
+	for _, rec := range st.Records {
+		if rec.key() != key || rec.DueDate == 0 {
+			continue
+		}
+		if prev, ok := byDue[rec.DueDate]; !ok || rec.RetrievedAt >= prev.RetrievedAt {
+			byDue[rec.DueDate] = rec
+		}
+	}
+
+	cycles := make([]billRecord, 0, len(byDue))
+	for _, rec := range byDue {
+		cycles = append(cycles, rec)
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].DueDate < cycles[j].DueDate })
+	return cycles
+}
+
+// previousCycle returns the most recent cycle of a bill series that is due before the given due date.
+func (st *store) previousCycle(key billKey, dueDate int64) (billRecord, bool) {
+	cycles := st.cycles(key)
+	for i := len(cycles) - 1; i >= 0; i-- {
+		if cycles[i].DueDate < dueDate {
+			return cycles[i], true
+		}
+	}
+	return billRecord{}, false
+}
+
+// bill rebuilds the bill a record was made from. Only retrieved bills are stored, and their amount was read from the site unless the run saw the status as unknown; records from before statuses were stored have an amount too.
+func (rec billRecord) bill() *Bill {
+	return &Bill{
+		amountDue:            rec.AmountDue,
+		amountParsed:         rec.Status != statusUnknown,
+		dueDate:              rec.DueDate,
+		retrieved:            true,
+		principalBalance:     rec.PrincipalBalance,
+		statementBalance:     rec.StatementBalance,
+		currentBalance:       rec.CurrentBalance,
+		lastPaymentAmount:    rec.LastPaymentAmount,
+		lastPaymentDate:      rec.LastPaymentDate,
+		autopay:              rec.Autopay,
+		scheduledPaymentDate: rec.ScheduledPaymentDate,
+		usage:                usage{rec.Usage, rec.UsageUnit, rec.PeriodStart, rec.PeriodEnd},
+	}
+}
+
+// keys returns every bill series present in the store, sorted by bill type and account.
+func (st *store) keys() []billKey {
+	seen := map[billKey]bool{}
+	var keys []billKey
+	for _, rec := range st.Records {
+		if !seen[rec.key()] {
+			seen[rec.key()] = true
+			keys = append(keys, rec.key())
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+		return keys[i].Account < keys[j].Account
+	})
+	return keys
+}
+
+// latestBills rebuilds the most recent cycle of every bill series as a bill list, for commands that run without scraping.
+func (st *store) latestBills() []billEntry {
+	var bills []billEntry
+	for _, key := range st.keys() {
+		cycles := st.cycles(key)
+		if len(cycles) == 0 {
+			continue
+		}
+		last := cycles[len(cycles)-1]
+		bill := last.bill()
+		if m, ok := st.match(key, last.DueDate); ok {
+			bill.paidReference = m.Reference
+		}
+		bills = append(bills, billEntry{
+			provider:  last.Provider,
+			name:      key.Type,
+			account:   key.Account,
+			household: last.Household,
+			bill:      bill,
+		})
+	}
+	return bills
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTripStatus(t *testing.T) {
+	due := date("2026-05-20").Unix()
+	bills := []billEntry{
+		{name: "Power", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 0, dueDate: due}},
+		{name: "Gas", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 40, dueDate: due}},
+		{name: "Water", bill: &Bill{retrieved: true, amountParsed: true, amountDue: 62.10, dueDate: due, autopay: true}},
+		{name: "Internet", bill: &Bill{retrieved: true, amountDue: 0, dueDate: due}}, // balance never read
+		{name: "Wireless", bill: &Bill{}}, // not retrieved, so not stored
+	}
+	want := map[string]string{"Power": statusPaid, "Gas": statusUnpaid, "Water": statusScheduled, "Internet": statusUnknown}
+
+	path := filepath.Join(t.TempDir(), "store.json")
+	st, err := loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.addRun(bills, time.Now())
+	if err := st.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, entry := range loaded.latestBills() {
+		got[entry.name] = entry.bill.status()
+	}
+	if len(got) != len(want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s status = %q after reloading, want %q", name, got[name], status)
+		}
+	}
+}
+
+func TestStoredRecordWithoutStatus(t *testing.T) {
+	// Records written before statuses were stored still carry an amount read from the site
+	rec := billRecord{Type: "Gas", AmountDue: 40, DueDate: date("2026-05-20").Unix()}
+	if got := rec.bill().status(); got != statusUnpaid {
+		t.Errorf("status = %q, want %q", got, statusUnpaid)
+	}
+}
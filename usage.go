@@ -0,0 +1,148 @@
+package main
+
+import (
+	"billburner/cd"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Units usage is normalized to before it is stored and written to the sinks
+const (
+	unitKWh     = "kWh"
+	unitTherms  = "therms"
+	unitGallons = "gallons"
+)
+
+// Conversions from the units the sites sometimes bill in
+const (
+	thermsPerCCF  = 1.037 // typical heat content of natural gas, the exact factor is printed on each Spire statement
+	gallonsPerCCF = 748.052
+)
+
+// usage is the quantity a utility bill charges for over one billing period
+type usage struct {
+	value       float64
+	unit        string
+	periodStart int64
+	periodEnd   int64
+}
+
+var (
+	usageRe         = regexp.MustCompile(`(?i)([0-9][0-9,]*(?:\.[0-9]+)?)\s*(kWh|therms?|CCF|gallons|gal)\b`)
+	labeledUsageRe  = regexp.MustCompile(`(?i)(?:usage|used|consumption)(?:\s+(?:this|for this|for the)\s+(?:billing\s+)?(?:period|cycle|month|bill))?[^0-9\n]{0,40}?([0-9][0-9,]*(?:\.[0-9]+)?)\s*(kWh|therms?|CCF|gallons|gal)\b`)
+	perDayRe        = regexp.MustCompile(`(?i)^\s*(?:/|per)\s*(?:day|d)\b`)
+	comparisonRe    = regexp.MustCompile(`(?i)(?:average|avg|daily|last year|previous|prior|same month|compared|neighbou?rs?)[^0-9]*$`)
+	usageContextLen = 40
+	usagePeriodRe   = regexp.MustCompile(`(?i)(?:service|billing|bill) (?:period|dates?|from)[^0-9A-Za-z]{0,10}` + statementDate + `\s*(?:-|–|to|through|thru)\s*` + statementDate)
+)
+
+// rate is the effective price per unit, everything on the bill included
+func (u usage) rate(amount float64) float64 {
+	if u.value <= 0 {
+		return 0
+	}
+	return amount / u.value
+}
+
+// unitRate is the effective price per unit of the bill's usage. The statement balance is used when known, since the amount due drops to zero once the bill is paid.
+func (b *Bill) unitRate() float64 {
+	charged := b.statementBalance
+	if charged == 0 {
+		charged = b.amountDue
+	}
+	return b.usage.rate(charged)
+}
+
+// perDay spreads the usage over the billing period, which makes short and long cycles comparable
+func (u usage) perDay() float64 {
+	if u.periodStart == 0 || u.periodEnd <= u.periodStart {
+		return 0
+	}
+	days := float64(u.periodEnd-u.periodStart) / (24 * 60 * 60)
+	return u.value / days
+}
+
+func (u usage) String() string {
+	if u.unit == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", strconv.FormatFloat(u.value, 'f', -1, 64), u.unit)
+}
+
+// normalizeUsage converts a quantity to the unit the bill type is tracked in. It returns false when the units are unrelated.
+func normalizeUsage(value float64, unit, want string) (float64, bool) {
+	unit = strings.ToLower(unit)
+	switch want {
+	case unitKWh:
+		return value, unit == "kwh"
+	case unitTherms:
+		switch unit {
+		case "therm", "therms":
+			return value, true
+		case "ccf":
+			return value * thermsPerCCF, true
+		}
+	case unitGallons:
+		switch unit {
+		case "gallons", "gal":
+			return value, true
+		case "ccf":
+			return value * gallonsPerCCF, true
+		}
+	}
+	return 0, false
+}
+
+// scrapeUsage reads the billed usage and the billing period from the part of the current page a provider shows usage in. Providers call it once that part is loaded.
+func scrapeUsage(bill *Bill, selector, unit string) {
+	text := cd.GetText(browser, selector)
+	bill.usage = parseUsage(text, unit)
+}
+
+// parseUsage finds the quantity billed for the period, along with the period itself. A quantity labelled as this period's usage wins; otherwise the first one that can be expressed in unit is taken. Daily rates and comparisons such as averages or last year's usage are skipped either way.
+func parseUsage(text, unit string) usage {
+	u := usage{}
+	for _, re := range []*regexp.Regexp{labeledUsageRe, usageRe} {
+		if v, ok := findUsage(re, text, unit); ok {
+			u.value = roundCents(v)
+			u.unit = unit
+			break
+		}
+	}
+	if u.unit == "" {
+		return u
+	}
+
+	if m := usagePeriodRe.FindStringSubmatch(text); m != nil {
+		u.periodStart = parsePaymentDate(m[1])
+		u.periodEnd = parsePaymentDate(m[2])
+	}
+	return u
+}
+
+// findUsage returns the first quantity re matches that is billed usage in a unit convertible to unit
+func findUsage(re *regexp.Regexp, text, unit string) (float64, bool) {
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		number, unitName := text[m[2]:m[3]], text[m[4]:m[5]]
+		if perDayRe.MatchString(text[m[1]:]) || comparisonRe.MatchString(text[max(0, m[2]-usageContextLen):m[2]]) {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", ""), 64)
+		if err != nil {
+			continue
+		}
+		if v, ok := normalizeUsage(value, unitName, unit); ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// usageUnits is the unit each metered bill type is tracked in
+var usageUnits = map[string]string{
+	"Power": unitKWh,
+	"Gas":   unitTherms,
+	"Water": unitGallons,
+}
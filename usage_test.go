@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestParseUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		unit string
+		want float64
+	}{
+		{"labelled usage after other figures", "Average daily use 21 kWh/day. Last year 610 kWh. Usage this period: 642 kWh", unitKWh, 642},
+		{"labelled usage first", "Total used 38 therms, about 1.2 therms per day", unitTherms, 38},
+		{"unlabelled skips rates and comparisons", "1.3 therms/day, compared to 41 therms, billed 44 therms", unitTherms, 44},
+		{"CCF converted", "Consumption for this billing period 5 CCF", unitGallons, 3740.26},
+		{"unrelated units only", "Usage this period: 642 kWh", unitGallons, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseUsage(tt.text, tt.unit); got.value != tt.want {
+				t.Errorf("parseUsage(%q) = %v, want %v", tt.text, got.value, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUsagePeriod(t *testing.T) {
+	u := parseUsage("Usage this period: 642 kWh. Service period 03/04/2026 - 04/02/2026", unitKWh)
+	if u.periodStart == 0 || u.periodEnd <= u.periodStart {
+		t.Fatalf("period = %d to %d, want both dates", u.periodStart, u.periodEnd)
+	}
+	if days := (u.periodEnd - u.periodStart) / (24 * 60 * 60); days != 29 {
+		t.Errorf("period spans %d days, want 29", days)
+	}
+}